@@ -0,0 +1,428 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every API key.
+	apiKeyPrefix = "ak_"
+
+	// apiKeyIDBytes is the length of the random key ID.
+	apiKeyIDBytes = 6
+
+	// apiKeyIDAttempts is the number of IDs CreateAPIKey tries before
+	// giving up on finding an unused one.
+	apiKeyIDAttempts = 3
+
+	// apiKeySecretBytes is the length of the random key secret.
+	apiKeySecretBytes = 32
+
+	// apiKeyTouchInterval is the minimum interval between last-used writes.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKey describes an API key. The secret is only returned by CreateAPIKey.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"` // Displayable start of the key, e.g. "ak_1a2b3c4d5e6f"
+	OwnerID    string    `json:"owner_id"`
+	Scopes     []string  `json:"scopes,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`   // Zero if the key does not expire
+	LastUsedAt time.Time `json:"last_used_at,omitempty"` // Updated at most once a minute; not stored with the key
+}
+
+// CreateAPIKeyOptions contains options for CreateAPIKey.
+type CreateAPIKeyOptions struct {
+	Name      string        // Display name
+	Scopes    []string      // Scopes granted to the key
+	ExpiresIn time.Duration // Lifetime of the key; zero for no expiry
+}
+
+// CreatedAPIKey is a new API key together with its secret.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"` // Full key; it cannot be retrieved again
+}
+
+// APIKeyInfo is the result of a successful API key validation. Either
+// User or ServiceAccount is set, depending on the key's owner.
+type APIKeyInfo struct {
+	APIKey         *APIKey
+	User           *User
+	ServiceAccount *ServiceAccount
+}
+
+// apiKeyRecord is the stored form of an API key.
+type apiKeyRecord struct {
+	APIKey
+	SecretHash string `json:"secret_hash"` // Hex SHA-256 of the full key
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey creates an API key owned by the user or service account
+// with ownerID.
+//
+// Keys look like "ak_<id>_<secret>". Only a SHA-256 hash of the key is
+// stored, so the returned Key must be shown to the owner right away.
+func (c *Client) CreateAPIKey(ctx context.Context, ownerID string, opts *CreateAPIKeyOptions) (_ *CreatedAPIKey, err error) {
+	const op = "Client.CreateAPIKey"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if opts == nil {
+		opts = &CreateAPIKeyOptions{}
+	}
+	if ownerID == "" || opts.ExpiresIn < 0 {
+		return nil, NewAppError(op, ErrInvalidInput, "owner and a non-negative expiry are required", 400)
+	}
+
+	if IsServiceAccountID(ownerID) {
+		_, err = c.GetServiceAccount(ctx, ownerID)
+	} else {
+		_, err = c.GetUserByID(ctx, ownerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := c.newAPIKeyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, NewAppError(op, err, "failed to generate API key", 500)
+	}
+	prefix := apiKeyPrefix + id
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	record := &apiKeyRecord{
+		APIKey: APIKey{
+			ID:        id,
+			Name:      opts.Name,
+			Prefix:    prefix,
+			OwnerID:   ownerID,
+			Scopes:    opts.Scopes,
+			CreatedAt: now,
+		},
+		SecretHash: hashSecret(key),
+	}
+	if opts.ExpiresIn > 0 {
+		record.ExpiresAt = now.Add(opts.ExpiresIn)
+	}
+
+	if err := c.saveAPIKey(ctx, record); err != nil {
+		return nil, kvError(op, err, "failed to save API key")
+	}
+	if err := c.kvSet(ctx, getAPIKeyOwnerKey(ownerID, id), []byte(id), apiKeyWriteOptions(record)); err != nil {
+		return nil, kvError(op, err, "failed to save API key owner index")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditAPIKeyCreated, UserID: ownerID, Details: map[string]string{"key_id": id}})
+
+	return &CreatedAPIKey{APIKey: record.APIKey, Key: key}, nil
+}
+
+// newAPIKeyID returns a random key ID not used by a stored key. IDs are
+// short enough to collide, and writing over a key would hand its owner's
+// access to someone else, so storage that cannot answer fails the creation.
+func (c *Client) newAPIKeyID(ctx context.Context) (string, error) {
+	const op = "Client.CreateAPIKey"
+
+	for attempt := 0; attempt < apiKeyIDAttempts; attempt++ {
+		id, err := randomHex(apiKeyIDBytes)
+		if err != nil {
+			return "", NewAppError(op, err, "failed to generate API key", 500)
+		}
+
+		_, err = c.getAPIKey(ctx, id)
+		if isKVNotFound(err) {
+			return id, nil
+		}
+		if err != nil {
+			return "", kvError(op, err, "failed to check for an existing API key")
+		}
+	}
+
+	return "", NewAppError(op, errors.New("API key ID collision"), "failed to generate API key", 500)
+}
+
+// ListAPIKeys returns the API keys of an owner, without their secrets.
+func (c *Client) ListAPIKeys(ctx context.Context, ownerID string) (_ []APIKey, err error) {
+	const op = "Client.ListAPIKeys"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if ownerID == "" {
+		return nil, NewAppError(op, ErrInvalidInput, "owner is required", 400)
+	}
+
+	var keys []APIKey
+	prefix := getAPIKeyOwnerKey(ownerID, "")
+	cursor := ""
+	for {
+		page, next, err := c.kvList(ctx, prefix, kvListMaxLimit, cursor)
+		if err != nil {
+			return nil, kvError(op, err, "failed to list API keys")
+		}
+
+		for _, item := range page {
+			record, err := c.getAPIKey(ctx, strings.TrimPrefix(item.Name, prefix))
+			if err != nil {
+				if isKVNotFound(err) {
+					// Revoked while listing
+					continue
+				}
+				return nil, kvError(op, err, "failed to read API key")
+			}
+			if record.LastUsedAt, err = c.apiKeyLastUsed(ctx, record.ID); err != nil {
+				return nil, kvError(op, err, "failed to read API key last-used time")
+			}
+			keys = append(keys, record.APIKey)
+		}
+
+		if next == "" {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// RevokeAPIKey deletes an API key of the user or service account with
+// ownerID. Keys of other owners are reported as not found. Validation
+// reads keys past the KV read cache, so requests using it fail from then
+// on.
+func (c *Client) RevokeAPIKey(ctx context.Context, ownerID, keyID string) (err error) {
+	const op = "Client.RevokeAPIKey"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	record, err := c.getAPIKey(ctx, keyID)
+	if err != nil {
+		if isKVNotFound(err) {
+			return NewAppError(op, ErrInvalidAPIKey, "API key not found", 404)
+		}
+		return kvError(op, err, "failed to read API key")
+	}
+	if ownerID == "" || record.OwnerID != ownerID {
+		return NewAppError(op, ErrInvalidAPIKey, "API key not found", 404)
+	}
+
+	if err := c.kvDelete(ctx, getAPIKeyKey(keyID)); err != nil {
+		return kvError(op, err, "failed to revoke API key")
+	}
+	if err := c.kvDelete(ctx, getAPIKeyOwnerKey(record.OwnerID, keyID)); err != nil && !isKVNotFound(err) {
+		return kvError(op, err, "failed to remove API key from owner index")
+	}
+	if err := c.kvDelete(ctx, getAPIKeyUsedKey(keyID)); err != nil && !isKVNotFound(err) {
+		return kvError(op, err, "failed to delete API key last-used time")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditAPIKeyRevoked, UserID: record.OwnerID, Details: map[string]string{"key_id": keyID}})
+
+	return nil
+}
+
+// ValidateAPIKey checks an API key and returns it together with its owner.
+//
+// The key's last-used time is updated at most once a minute under its own
+// KV key, so validation never rewrites the key itself; a failure to update
+// it is logged and does not fail the validation.
+func (c *Client) ValidateAPIKey(ctx context.Context, key string) (_ *APIKeyInfo, err error) {
+	const op = "Client.ValidateAPIKey"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	id, ok := parseAPIKey(key)
+	if !ok {
+		return nil, NewAppError(op, ErrInvalidAPIKey, "invalid API key", 401)
+	}
+
+	record, err := c.getAPIKey(ctx, id)
+	if err != nil {
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrInvalidAPIKey, "invalid API key", 401)
+		}
+		return nil, kvError(op, err, "failed to read API key")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(key)), []byte(record.SecretHash)) != 1 {
+		return nil, NewAppError(op, ErrInvalidAPIKey, "invalid API key", 401)
+	}
+
+	now := time.Now()
+	if !record.ExpiresAt.IsZero() && now.After(record.ExpiresAt) {
+		return nil, NewAppError(op, ErrInvalidAPIKey, "API key has expired", 401)
+	}
+
+	info := &APIKeyInfo{APIKey: &record.APIKey}
+	if IsServiceAccountID(record.OwnerID) {
+		account, err := c.getServiceAccount(ctx, record.OwnerID)
+		if err != nil {
+			if isKVNotFound(err) {
+				return nil, NewAppError(op, ErrInvalidAPIKey, "API key owner no longer exists", 401)
+			}
+			return nil, kvError(op, err, "failed to read service account")
+		}
+		if account.Disabled {
+			return nil, NewAppError(op, ErrInvalidAPIKey, "API key owner is disabled", 401)
+		}
+		info.ServiceAccount = &account.ServiceAccount
+	} else {
+		user, err := c.lookupUser(ctx, record.OwnerID, now)
+		if err != nil {
+			if IsUserNotFound(err) {
+				return nil, NewAppError(op, ErrInvalidAPIKey, "API key owner no longer exists", 401)
+			}
+			return nil, err
+		}
+		info.User = user
+	}
+
+	if err := c.touchAPIKey(ctx, record, now); err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "API key last-used time not updated",
+			slog.String(logKeyOp, op),
+			slog.String("api_key.id", record.ID),
+			slog.String(logKeyOutcome, kvOutcome(err)),
+		)
+	}
+
+	return info, nil
+}
+
+// apiKeyLastUsed returns the last-used time of a key, or zero if it has
+// not been used
+func (c *Client) apiKeyLastUsed(ctx context.Context, id string) (time.Time, error) {
+	var t time.Time
+	data, err := c.kvFetch(ctx, getAPIKeyUsedKey(id))
+	if err != nil {
+		if isKVNotFound(err) {
+			return t, nil
+		}
+		return t, err
+	}
+	if err := t.UnmarshalText(data); err != nil {
+		return time.Time{}, nil
+	}
+	return t, nil
+}
+
+// touchAPIKey records that a key was used at now, unless it was already
+// recorded within apiKeyTouchInterval, and sets record.LastUsedAt. The
+// entry expires with the key.
+func (c *Client) touchAPIKey(ctx context.Context, record *apiKeyRecord, now time.Time) error {
+	last, err := c.apiKeyLastUsed(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	record.LastUsedAt = last
+	if now.Sub(last) < apiKeyTouchInterval {
+		return nil
+	}
+	record.LastUsedAt = now
+
+	data, err := now.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return c.kvSet(ctx, getAPIKeyUsedKey(record.ID), data, apiKeyWriteOptions(record))
+}
+
+// getAPIKey reads a stored API key, bypassing the KV cache
+func (c *Client) getAPIKey(ctx context.Context, id string) (*apiKeyRecord, error) {
+	data, err := c.kvFetch(ctx, getAPIKeyKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var record apiKeyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// saveAPIKey stores an API key, expiring it in KV along with the key
+func (c *Client) saveAPIKey(ctx context.Context, record *apiKeyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return c.kvSet(ctx, getAPIKeyKey(record.ID), data, apiKeyWriteOptions(record))
+}
+
+// apiKeyWriteOptions expires the KV entries of a key once the key has
+// expired; KV requires expirations at least a minute ahead
+func apiKeyWriteOptions(record *apiKeyRecord) *KVWriteOptions {
+	if record.ExpiresAt.IsZero() || time.Until(record.ExpiresAt) <= minKVExpirationTTL {
+		return nil
+	}
+	return &KVWriteOptions{Expiration: record.ExpiresAt}
+}
+
+// parseAPIKey returns the ID of a key of the form "ak_<id>_<secret>"
+func parseAPIKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 2*apiKeyIDBytes || secret == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// hashSecret hashes a high-entropy secret such as an API key; unlike
+// passwords these need no slow hash
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func getAPIKeyKey(id string) string {
+	return "apikey:id:" + id
+}
+
+func getAPIKeyUsedKey(id string) string {
+	return "apikey:used:" + id
+}
+
+func getAPIKeyOwnerKey(ownerID, id string) string {
+	return "apikey:owner:" + ownerID + ":" + id
+}
@@ -0,0 +1,358 @@
+package cloudflare_auth_sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		wantID string
+		wantOK bool
+	}{
+		{key: "ak_1a2b3c4d5e6f_c2VjcmV0", wantID: "1a2b3c4d5e6f", wantOK: true},
+		{key: "ak_1a2b3c4d5e6f_secret_with_underscores", wantID: "1a2b3c4d5e6f", wantOK: true},
+		{key: ""},
+		{key: "ak_"},
+		{key: "1a2b3c4d5e6f_secret"},
+		{key: "pk_1a2b3c4d5e6f_secret"},
+		{key: "ak_1a2b3c4d5e6f"},
+		{key: "ak_1a2b3c4d5e6f_"},
+		{key: "ak_1a2b3c4d5e_secret"},
+		{key: "ak_1a2b3c4d5e6f7a_secret"},
+		{key: "ak_zzzzzzzzzzzz_secret"},
+		{key: "eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			id, ok := parseAPIKey(tt.key)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Fatalf("parseAPIKey(%q) = %q, %v; want %q, %v", tt.key, id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	user, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := client.CreateAPIKey(ctx, user.ID, &CreateAPIKeyOptions{Name: "ci", Scopes: []string{"reports:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := kv.get(getAPIKeyKey(created.ID))
+
+	info, err := client.ValidateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("ValidateAPIKey: %v", err)
+	}
+	if info.User == nil || info.User.ID != user.ID || info.ServiceAccount != nil {
+		t.Fatalf("owner %+v, %+v; want user %s", info.User, info.ServiceAccount, user.ID)
+	}
+	if !info.APIKey.HasScope("reports:read") || info.APIKey.LastUsedAt.IsZero() {
+		t.Fatalf("key %+v, want its scope and last-used time", info.APIKey)
+	}
+
+	// The last-used time has its own entry; the key itself is never rewritten
+	if data, _ := kv.get(getAPIKeyKey(created.ID)); !bytes.Equal(data, stored) {
+		t.Fatal("validation rewrote the stored key")
+	}
+	if _, ok := kv.get(getAPIKeyUsedKey(created.ID)); !ok {
+		t.Fatal("last-used time not stored")
+	}
+
+	// Within a minute the last-used time is not written again
+	puts := kv.count("PUT values")
+	if _, err := client.ValidateAPIKey(ctx, created.Key); err != nil {
+		t.Fatal(err)
+	}
+	if kv.count("PUT values") != puts {
+		t.Fatal("last-used time written twice within a minute")
+	}
+
+	keys, err := client.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].LastUsedAt.Equal(info.APIKey.LastUsedAt) {
+		t.Fatalf("listed %+v, want last used at %v", keys, info.APIKey.LastUsedAt)
+	}
+
+	// Expired keys fail even if KV has not dropped them yet
+	expired := &apiKeyRecord{}
+	if err := json.Unmarshal(stored, expired); err != nil {
+		t.Fatal(err)
+	}
+	expired.ID = "0123456789ab"
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	expiredKey := apiKeyPrefix + expired.ID + "_secret"
+	expired.SecretHash = hashSecret(expiredKey)
+	data, _ := json.Marshal(expired)
+	kv.put(getAPIKeyKey(expired.ID), data)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "malformed", key: "not-a-key"},
+		{name: "unknown ID", key: apiKeyPrefix + "ffffffffffff_secret"},
+		{name: "wrong secret", key: apiKeyPrefix + created.ID + "_wrong"},
+		{name: "expired", key: expiredKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ValidateAPIKey(ctx, tt.key); !IsInvalidAPIKey(err) {
+				t.Fatalf("ValidateAPIKey = %v, want ErrInvalidAPIKey", err)
+			}
+		})
+	}
+
+	if err := client.DeleteUser(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); !IsInvalidAPIKey(err) {
+		t.Fatalf("key of a deleted user = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestRevokeAPIKeyChecksOwner(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	alice, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := client.Register(ctx, "bob@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := client.CreateAPIKey(ctx, alice.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, owner := range []string{bob.ID, ""} {
+		err := client.RevokeAPIKey(ctx, owner, created.ID)
+		var appErr *AppError
+		if !errors.As(err, &appErr) || appErr.Code != http.StatusNotFound || !IsInvalidAPIKey(err) {
+			t.Fatalf("revoke as %q = %v, want 404", owner, err)
+		}
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); err != nil {
+		t.Fatalf("key revoked by another owner: %v", err)
+	}
+
+	if err := client.RevokeAPIKey(ctx, alice.ID, created.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if keys := kv.keys("apikey:"); len(keys) != 0 {
+		t.Fatalf("KV keys %v left after revoking", keys)
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); !IsInvalidAPIKey(err) {
+		t.Fatalf("revoked key = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestMiddlewareCredentialPrecedence(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	login, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := client.CreateServiceAccount(ctx, &ServiceAccountOptions{Name: "reports"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := client.CreateAPIKey(ctx, account.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalidKey := apiKeyPrefix + created.ID + "_wrong"
+
+	tests := []struct {
+		name          string
+		opts          MiddlewareOptions
+		apiKey        string // X-API-Key header
+		authorization string
+		wantStatus    int
+		want          string // "user", "service account" or "anonymous"
+	}{
+		{name: "token", authorization: "Bearer " + login.Token, wantStatus: http.StatusOK, want: "user"},
+		{name: "API keys disabled", apiKey: created.Key, wantStatus: http.StatusUnauthorized},
+		{name: "API key header", opts: MiddlewareOptions{APIKeys: true}, apiKey: created.Key, wantStatus: http.StatusOK, want: "service account"},
+		{name: "API key as bearer", opts: MiddlewareOptions{APIKeys: true}, authorization: "Bearer " + created.Key, wantStatus: http.StatusOK, want: "service account"},
+		{name: "API key wins over token", opts: MiddlewareOptions{APIKeys: true}, apiKey: created.Key, authorization: "Bearer " + login.Token, wantStatus: http.StatusOK, want: "service account"},
+		{name: "invalid API key does not fall back to token", opts: MiddlewareOptions{APIKeys: true}, apiKey: invalidKey, authorization: "Bearer " + login.Token, wantStatus: http.StatusUnauthorized},
+		{name: "optional without credentials", opts: MiddlewareOptions{APIKeys: true, Optional: true}, wantStatus: http.StatusOK, want: "anonymous"},
+		{name: "optional with an invalid API key", opts: MiddlewareOptions{APIKeys: true, Optional: true}, apiKey: invalidKey, wantStatus: http.StatusUnauthorized},
+		{name: "optional with an invalid token", opts: MiddlewareOptions{Optional: true}, authorization: "Bearer invalid", wantStatus: http.StatusUnauthorized},
+		{name: "claims only with token", opts: MiddlewareOptions{APIKeys: true, ClaimsOnly: true}, authorization: "Bearer " + login.Token, wantStatus: http.StatusOK, want: "user"},
+		{name: "claims only still validates API keys", opts: MiddlewareOptions{APIKeys: true, ClaimsOnly: true}, apiKey: invalidKey, wantStatus: http.StatusUnauthorized},
+		{name: "claims only with API key", opts: MiddlewareOptions{APIKeys: true, ClaimsOnly: true}, apiKey: created.Key, wantStatus: http.StatusOK, want: "service account"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			var got string
+			handler := client.Middleware(&opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, fromKey := APIKeyFromContext(r.Context())
+				_, fromToken := ClaimsFromContext(r.Context())
+				account, _ := ServiceAccountFromContext(r.Context())
+				switch {
+				case fromKey && account != nil && account.ID == created.OwnerID:
+					got = "service account"
+				case fromToken:
+					got = "user"
+				default:
+					got = "anonymous"
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.want {
+				t.Fatalf("authenticated as %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyAvoidsIDCollisions(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{Retry: &RetryPolicy{MaxAttempts: 1}})
+	user, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// collide stores a key under the first n IDs CreateAPIKey checks
+	collide := func(n int) *[]string {
+		var taken []string
+		kv.fail = func(r *http.Request) int {
+			_, key, ok := strings.Cut(r.URL.Path, "/values/")
+			if r.Method != http.MethodGet || !ok || !strings.HasPrefix(key, "apikey:id:") || len(taken) >= n {
+				return 0
+			}
+			taken = append(taken, key)
+			kv.put(key, []byte(`{"id":"taken"}`))
+			return 0
+		}
+		return &taken
+	}
+
+	taken := collide(2)
+	created, err := client.CreateAPIKey(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*taken) != 2 {
+		t.Fatalf("%d taken IDs checked, want 2", len(*taken))
+	}
+	for _, key := range *taken {
+		if key == getAPIKeyKey(created.ID) {
+			t.Fatalf("key created with the taken ID %s", created.ID)
+		}
+		if data, _ := kv.get(key); string(data) != `{"id":"taken"}` {
+			t.Fatalf("existing key %s overwritten with %s", key, data)
+		}
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); err != nil {
+		t.Fatalf("ValidateAPIKey: %v", err)
+	}
+
+	// No unused ID found, or storage cannot answer
+	writes := kv.count("PUT values")
+	collide(apiKeyIDAttempts)
+	var appErr *AppError
+	if _, err := client.CreateAPIKey(ctx, user.ID, nil); !errors.As(err, &appErr) || appErr.Code != http.StatusInternalServerError {
+		t.Fatalf("CreateAPIKey with every ID taken = %v, want 500", err)
+	}
+	kv.fail = func(r *http.Request) int {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/values/apikey:id:") {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	if _, err := client.CreateAPIKey(ctx, user.ID, nil); !IsKVOperationFailed(err) {
+		t.Fatalf("CreateAPIKey with KV failing = %v, want a KV error", err)
+	}
+	if n := kv.count("PUT values") - writes; n != 0 {
+		t.Fatalf("%d writes by failed creations", n)
+	}
+}
+
+func TestDeleteUserRevokesAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	sink := &recordingAuditSink{}
+	client.SetAuditSink(sink)
+
+	user, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := client.CreateAPIKey(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteUser(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateAPIKey(ctx, created.Key); !IsInvalidAPIKey(err) {
+		t.Fatalf("ValidateAPIKey after deleting the owner = %v, want invalid API key", err)
+	}
+	if keys := kv.keys("apikey:"); len(keys) != 0 {
+		t.Fatalf("API keys %v left after deleting the user", keys)
+	}
+
+	sink.mu.Lock()
+	for _, event := range sink.events {
+		if (event.Action == AuditAPIKeyCreated || event.Action == AuditAPIKeyRevoked) &&
+			(event.UserID != user.ID || event.Details["key_id"] != created.ID) {
+			t.Errorf("%s event %+v, want the owner and key IDs", event.Action, event)
+		}
+	}
+	sink.mu.Unlock()
+
+	want := []AuditAction{AuditUserRegistered, AuditAPIKeyCreated, AuditAPIKeyRevoked, AuditUserDeleted}
+	if got := sink.actions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit actions %v, want %v", got, want)
+	}
+}
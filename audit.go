@@ -0,0 +1,170 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction identifies the kind of an audit event.
+type AuditAction string
+
+// Actions recorded by the client. Applications may record their own actions,
+// such as password or role changes, with Client.RecordAuditEvent.
+const (
+	AuditUserRegistered AuditAction = "user.registered"
+	AuditUserDeleted    AuditAction = "user.deleted"
+	AuditLoginSucceeded AuditAction = "login.succeeded"
+	AuditLoginFailed    AuditAction = "login.failed"
+	AuditSessionRevoked AuditAction = "session.revoked"
+
+	// Service account events carry the account ID in UserID
+	AuditServiceAccountCreated       AuditAction = "service_account.created"
+	AuditServiceAccountUpdated       AuditAction = "service_account.updated"
+	AuditServiceAccountDisabled      AuditAction = "service_account.disabled"
+	AuditServiceAccountDeleted       AuditAction = "service_account.deleted"
+	AuditServiceAccountSecretRotated AuditAction = "service_account.secret_rotated"
+	AuditClientCredentialsSucceeded  AuditAction = "client_credentials.succeeded"
+	AuditClientCredentialsFailed     AuditAction = "client_credentials.failed"
+
+	// API key events carry the owner ID in UserID and the key ID in
+	// Details["key_id"]
+	AuditAPIKeyCreated AuditAction = "api_key.created"
+	AuditAPIKeyRevoked AuditAction = "api_key.revoked"
+)
+
+// AuditEvent is one entry of the audit trail.
+type AuditEvent struct {
+	ID        string            `json:"id"`
+	Time      time.Time         `json:"time"`
+	Action    AuditAction       `json:"action"`
+	UserID    string            `json:"user_id,omitempty"` // Empty if no user was found, e.g. for a failed login
+	Email     string            `json:"email,omitempty"`
+	SessionID string            `json:"session_id,omitempty"`
+	Reason    string            `json:"reason,omitempty"` // Failure outcome, e.g. "invalid_credentials"
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// AuditQuery selects audit events.
+type AuditQuery struct {
+	UserID string    // Events of this user; empty for all events
+	Since  time.Time // Inclusive lower bound; zero for no bound
+	Until  time.Time // Exclusive upper bound; zero for now
+	Limit  int       // Maximum number of events; zero for no limit
+}
+
+// AuditSink stores audit events.
+//
+// Implementations must be safe for concurrent use. Sinks only append events;
+// none of the SDK sinks update or delete a recorded event.
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event *AuditEvent) error
+}
+
+// AuditQuerier is implemented by sinks that can list stored events.
+type AuditQuerier interface {
+	// QueryAuditEvents returns matching events, oldest first.
+	QueryAuditEvents(ctx context.Context, q *AuditQuery) ([]AuditEvent, error)
+}
+
+// SetAuditSink enables the audit trail. Registrations, logins, logouts of
+// sliding sessions, user deletions and changes to service accounts and API
+// keys are written to sink.
+//
+// It must be called before the client is used. Use MultiAuditSink to write
+// to several sinks.
+func (c *Client) SetAuditSink(sink AuditSink) {
+	c.auditSink = sink
+}
+
+// RecordAuditEvent writes an application-defined event to the audit sink.
+//
+// ID and Time are set if empty. Without an audit sink this is a no-op.
+func (c *Client) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	const op = "Client.RecordAuditEvent"
+
+	if event == nil || event.Action == "" {
+		return NewAppError(op, ErrInvalidInput, "audit action is required", 400)
+	}
+	if c.auditSink == nil {
+		return nil
+	}
+
+	prepareAuditEvent(event)
+	if err := c.auditSink.WriteAuditEvent(ctx, event); err != nil {
+		var appErr *AppError
+		if errors.As(err, &appErr) {
+			return err
+		}
+		return NewAppError(op, err, "failed to record audit event", 500)
+	}
+	return nil
+}
+
+// audit records an event of an SDK operation. A failure to record it is
+// logged but does not fail the operation.
+func (c *Client) audit(ctx context.Context, event *AuditEvent) {
+	if c.auditSink == nil {
+		return
+	}
+
+	prepareAuditEvent(event)
+
+	// The operation has completed; record it even if the caller gives up
+	ctx = context.WithoutCancel(ctx)
+	if err := c.auditSink.WriteAuditEvent(ctx, event); err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelError, "audit event not recorded",
+			slog.String("audit.action", string(event.Action)),
+			slog.String("audit.id", event.ID),
+			slog.String(logKeyUserID, event.UserID),
+			slog.String(logKeyOutcome, kvOutcome(err)),
+		)
+	}
+}
+
+func prepareAuditEvent(event *AuditEvent) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+}
+
+// matches reports whether the event is selected by the query, ignoring Limit
+func (q *AuditQuery) matches(event *AuditEvent) bool {
+	if q.UserID != "" && event.UserID != q.UserID {
+		return false
+	}
+	if !q.Since.IsZero() && event.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !event.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// MultiAuditSink returns a sink that writes every event to all sinks.
+//
+// An event is written to the remaining sinks even if one of them fails;
+// the returned error joins all failures.
+func MultiAuditSink(sinks ...AuditSink) AuditSink {
+	return multiAuditSink(sinks)
+}
+
+type multiAuditSink []AuditSink
+
+func (m multiAuditSink) WriteAuditEvent(ctx context.Context, event *AuditEvent) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
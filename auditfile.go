@@ -0,0 +1,109 @@
+package cloudflare_auth_sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// auditFileMaxLine is the longest line QueryAuditEvents reads.
+const auditFileMaxLine = 1 << 20
+
+// FileAuditSink appends audit events to a file as JSON lines.
+//
+// The file is opened in append mode and never truncated; rotation and
+// retention are left to external tools such as logrotate.
+type FileAuditSink struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+var _ AuditQuerier = (*FileAuditSink)(nil)
+
+// NewFileAuditSink opens or creates the file at path for appending events.
+// A new file is created with mode 0600.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditSink{
+		path: path,
+		file: file,
+	}, nil
+}
+
+// WriteAuditEvent implements AuditSink. Each event is written with a single
+// write call.
+func (s *FileAuditSink) WriteAuditEvent(_ context.Context, event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	_, err = s.file.Write(data)
+	return err
+}
+
+// QueryAuditEvents implements AuditQuerier by reading the whole file.
+func (s *FileAuditSink) QueryAuditEvents(ctx context.Context, q *AuditQuery) ([]AuditEvent, error) {
+	if q == nil {
+		q = &AuditQuery{}
+	}
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, auditFileMaxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return events, err
+		}
+
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, fmt.Errorf("%w: %s line %d: %w", ErrDecodeFailed, s.path, line, err)
+		}
+		if !q.matches(&event) {
+			continue
+		}
+
+		events = append(events, event)
+		if q.Limit > 0 && len(events) >= q.Limit {
+			break
+		}
+	}
+
+	return events, scanner.Err()
+}
+
+// Close closes the file. Later writes fail with os.ErrClosed.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
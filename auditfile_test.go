@@ -0,0 +1,159 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileAuditSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []*AuditEvent{
+		{ID: "e1", Time: base, Action: AuditUserRegistered, UserID: "u1"},
+		{ID: "e2", Time: base.Add(time.Minute), Action: AuditLoginFailed, Email: "nobody@example.com", Reason: "user_not_found"},
+		{ID: "e3", Time: base.Add(2 * time.Minute), Action: AuditLoginSucceeded, UserID: "u1"},
+		{ID: "e4", Time: base.Add(3 * time.Minute), Action: AuditLoginSucceeded, UserID: "u2"},
+	}
+	for _, event := range events {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("file mode %v, want 0600", mode)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != len(events) {
+		t.Fatalf("%d lines, want one per event", lines)
+	}
+
+	tests := []struct {
+		name  string
+		query *AuditQuery
+		want  []string
+	}{
+		{name: "all", query: nil, want: []string{"e1", "e2", "e3", "e4"}},
+		{name: "one user", query: &AuditQuery{UserID: "u1"}, want: []string{"e1", "e3"}},
+		{name: "range", query: &AuditQuery{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, want: []string{"e2", "e3"}},
+		{name: "limit", query: &AuditQuery{Limit: 3}, want: []string{"e1", "e2", "e3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := sink.QueryAuditEvents(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := auditIDs(events); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("events %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got, err := sink.QueryAuditEvents(ctx, &AuditQuery{UserID: "u2"})
+	if err != nil || len(got) != 1 || got[0].Action != AuditLoginSucceeded || !got[0].Time.Equal(events[3].Time) {
+		t.Fatalf("queried event = %+v, %v; want %+v", got, err, events[3])
+	}
+}
+
+func TestFileAuditSinkAppends(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, id := range []string{"e1", "e2"} {
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.WriteAuditEvent(ctx, &AuditEvent{ID: id, Time: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	events, err := sink.QueryAuditEvents(ctx, nil)
+	if got := auditIDs(events); err != nil || fmt.Sprint(got) != "[e1 e2]" {
+		t.Fatalf("events %v, %v; want both events after reopening", got, err)
+	}
+}
+
+func TestFileAuditSinkClose(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteAuditEvent(ctx, &AuditEvent{ID: "e1", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+	if err := sink.WriteAuditEvent(ctx, &AuditEvent{ID: "e2", Time: time.Now()}); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("write after Close = %v, want os.ErrClosed", err)
+	}
+
+	// Written events can still be queried
+	events, err := sink.QueryAuditEvents(ctx, nil)
+	if got := auditIDs(events); err != nil || fmt.Sprint(got) != "[e1]" {
+		t.Fatalf("events %v, %v after Close", got, err)
+	}
+}
+
+func TestFileAuditSinkQueryInvalidLine(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte(`{"id":"e1"}`+"\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	events, err := sink.QueryAuditEvents(ctx, nil)
+	if !errors.Is(err, ErrDecodeFailed) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("query = %v, want ErrDecodeFailed for line 2", err)
+	}
+	if got := auditIDs(events); fmt.Sprint(got) != "[e1]" {
+		t.Fatalf("events before the invalid line %v, want [e1]", got)
+	}
+}
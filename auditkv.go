@@ -0,0 +1,243 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAuditRetention is the default time KV keeps audit events.
+	defaultAuditRetention = 90 * 24 * time.Hour
+
+	// auditDayLayout names the daily buckets of the global trail.
+	auditDayLayout = "20060102"
+)
+
+// KVAuditSinkOptions contains options for a KVAuditSink.
+type KVAuditSinkOptions struct {
+	Retention time.Duration // How long events are kept, at least one minute (default: 90 days)
+}
+
+// KVAuditSink stores audit events in the client's KV namespace.
+//
+// Every event is written twice in one bulk request: under
+// "audit:user:<user ID>:<time>:<event ID>" for per-user queries, and under
+// "audit:global:<day>:<time>:<event ID>" for the global trail. Times are
+// zero-padded nanoseconds, so keys list in chronological order. Events
+// expire after the retention period and are never rewritten.
+type KVAuditSink struct {
+	client    *Client
+	retention time.Duration
+}
+
+var _ AuditQuerier = (*KVAuditSink)(nil)
+
+// NewKVAuditSink creates an audit sink that stores events in the client's
+// KV namespace.
+//
+// Example:
+//
+//	sink, err := client.NewKVAuditSink(&sdk.KVAuditSinkOptions{
+//	    Retention: 365 * 24 * time.Hour,
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	client.SetAuditSink(sink)
+func (c *Client) NewKVAuditSink(opts *KVAuditSinkOptions) (*KVAuditSink, error) {
+	retention := defaultAuditRetention
+	if opts != nil && opts.Retention != 0 {
+		retention = opts.Retention
+	}
+	if retention < minKVExpirationTTL {
+		return nil, errors.New("audit retention must be at least one minute")
+	}
+
+	return &KVAuditSink{
+		client:    c,
+		retention: retention,
+	}, nil
+}
+
+// WriteAuditEvent implements AuditSink.
+func (s *KVAuditSink) WriteAuditEvent(ctx context.Context, event *AuditEvent) error {
+	const op = "KVAuditSink.WriteAuditEvent"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return NewAppError(op, err, "failed to encode audit event", 500)
+	}
+
+	// Small events are also stored as metadata so queries need no reads
+	var metadata interface{}
+	if len(data) <= kvMaxMetadataSize {
+		metadata = json.RawMessage(data)
+	}
+
+	ttl := int(s.retention / time.Second)
+	var pairs []KVPair
+	for _, key := range []string{auditUserKey(event), auditGlobalKey(event)} {
+		if key == "" {
+			continue
+		}
+		pairs = append(pairs, KVPair{
+			Key:           key,
+			Value:         data,
+			ExpirationTTL: ttl,
+			Metadata:      metadata,
+		})
+	}
+
+	if _, err := s.client.KVSetBulk(ctx, pairs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// QueryAuditEvents implements AuditQuerier.
+//
+// Queries of one user list that user's keys; queries of all events list one
+// daily bucket of the global trail per day in the range.
+func (s *KVAuditSink) QueryAuditEvents(ctx context.Context, q *AuditQuery) ([]AuditEvent, error) {
+	const op = "KVAuditSink.QueryAuditEvents"
+
+	if q == nil {
+		q = &AuditQuery{}
+	}
+
+	until := q.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	since := q.Since
+	if oldest := time.Now().Add(-s.retention); since.Before(oldest) {
+		since = oldest
+	}
+
+	var prefixes []string
+	if q.UserID != "" {
+		prefixes = []string{auditUserPrefix(q.UserID)}
+	} else {
+		for day := since.UTC().Truncate(24 * time.Hour); day.Before(until); day = day.Add(24 * time.Hour) {
+			prefixes = append(prefixes, "audit:global:"+day.Format(auditDayLayout)+":")
+		}
+	}
+
+	var events []AuditEvent
+	for _, prefix := range prefixes {
+		done, err := s.scan(ctx, prefix, since, until, q, &events)
+		if err != nil {
+			return events, kvError(op, err, "failed to query audit events")
+		}
+		if done {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// scan appends the matching events under prefix to events. It reports
+// whether the query is complete because the limit or the end of the range
+// was reached.
+func (s *KVAuditSink) scan(ctx context.Context, prefix string, since, until time.Time, q *AuditQuery, events *[]AuditEvent) (bool, error) {
+	cursor := ""
+	for {
+		keys, next, err := s.client.kvList(ctx, prefix, kvListMaxLimit, cursor)
+		if err != nil {
+			return false, err
+		}
+
+		for _, key := range keys {
+			at, ok := auditKeyTime(key.Name, prefix)
+			if !ok || at.Before(since) {
+				continue
+			}
+			if !at.Before(until) {
+				return true, nil
+			}
+
+			event, err := s.decode(ctx, key)
+			if err != nil {
+				if isKVNotFound(err) {
+					// Expired between the list and the read
+					continue
+				}
+				return false, err
+			}
+			if !q.matches(event) {
+				continue
+			}
+
+			*events = append(*events, *event)
+			if q.Limit > 0 && len(*events) >= q.Limit {
+				return true, nil
+			}
+		}
+
+		if next == "" {
+			return false, nil
+		}
+		cursor = next
+	}
+}
+
+// decode returns the event of a listed key, reading the value if the event
+// did not fit in the metadata
+func (s *KVAuditSink) decode(ctx context.Context, key KVKey) (*AuditEvent, error) {
+	var data []byte
+	if key.Metadata != nil {
+		var err error
+		if data, err = json.Marshal(key.Metadata); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = s.client.kvFetch(ctx, key.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	var event AuditEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("%w: audit event %s: %w", ErrDecodeFailed, key.Name, err)
+	}
+	return &event, nil
+}
+
+func auditUserPrefix(userID string) string {
+	return "audit:user:" + userID + ":"
+}
+
+// auditUserKey returns the per-user key of the event, or "" if the event
+// has no user
+func auditUserKey(event *AuditEvent) string {
+	if event.UserID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s%020d:%s", auditUserPrefix(event.UserID), event.Time.UnixNano(), event.ID)
+}
+
+func auditGlobalKey(event *AuditEvent) string {
+	t := event.Time.UTC()
+	return fmt.Sprintf("audit:global:%s:%020d:%s", t.Format(auditDayLayout), t.UnixNano(), event.ID)
+}
+
+// auditKeyTime parses the event time from a key listed under prefix
+func auditKeyTime(key, prefix string) (time.Time, bool) {
+	rest := strings.TrimPrefix(key, prefix)
+	stamp, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
@@ -0,0 +1,217 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditIDs returns the IDs of events
+func auditIDs(events []AuditEvent) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func newTestKVAuditSink(t *testing.T) (*KVAuditSink, *fakeKV) {
+	t.Helper()
+
+	client, kv := newTestClient(t, nil)
+	sink, err := client.NewKVAuditSink(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink, kv
+}
+
+func TestNewKVAuditSinkRetention(t *testing.T) {
+	client, _ := newTestClient(t, nil)
+
+	if _, err := client.NewKVAuditSink(&KVAuditSinkOptions{Retention: 30 * time.Second}); err == nil {
+		t.Fatal("retention below one minute accepted")
+	}
+	sink, err := client.NewKVAuditSink(nil)
+	if err != nil || sink.retention != defaultAuditRetention {
+		t.Fatalf("default sink = %+v, %v; want %v retention", sink, err, defaultAuditRetention)
+	}
+}
+
+func TestKVAuditSinkKeys(t *testing.T) {
+	ctx := context.Background()
+	sink, kv := newTestKVAuditSink(t)
+
+	at := time.Date(2026, 3, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	events := []*AuditEvent{
+		{ID: "e1", Time: at, Action: AuditLoginSucceeded, UserID: "u1"},
+		{ID: "e2", Time: at, Action: AuditLoginFailed, Email: "nobody@example.com"},
+	}
+	for _, event := range events {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Global buckets are named after the UTC day
+	stamp := fmt.Sprintf("%020d", at.UnixNano())
+	want := []string{
+		"audit:global:20260302:" + stamp + ":e1",
+		"audit:global:20260302:" + stamp + ":e2",
+		"audit:user:u1:" + stamp + ":e1",
+	}
+	if keys := kv.keys("audit:"); fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("keys %v, want %v", keys, want)
+	}
+	if n := kv.count("PUT bulk"); n != len(events) {
+		t.Fatalf("%d bulk writes, want one per event", n)
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, key := range want {
+		if kv.entries[key].expiration.IsZero() {
+			t.Errorf("%s does not expire", key)
+		}
+	}
+}
+
+func TestKVAuditSinkKeysListInTimeOrder(t *testing.T) {
+	ctx := context.Background()
+	sink, kv := newTestKVAuditSink(t)
+
+	// Times whose nanosecond counts have different lengths
+	times := []time.Time{time.Unix(0, 5), time.Unix(3, 0), time.Unix(0, 40)}
+	for i, at := range times {
+		event := &AuditEvent{ID: fmt.Sprintf("e%d", i), Time: at, UserID: "u1"}
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := kv.keys("audit:user:u1:")
+	var got []string
+	for _, key := range keys {
+		got = append(got, key[strings.LastIndex(key, ":")+1:])
+	}
+	if want := []string{"e0", "e2", "e1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("keys list as %v, want %v", got, want)
+	}
+}
+
+func TestKVAuditSinkQuery(t *testing.T) {
+	ctx := context.Background()
+	sink, _ := newTestKVAuditSink(t)
+
+	// Events over three UTC days, written out of order
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	events := []*AuditEvent{
+		{ID: "today-u1", Time: time.Now().Add(-time.Second), UserID: "u1"},
+		{ID: "two-days-ago-u1", Time: day.Add(-47 * time.Hour), UserID: "u1"},
+		{ID: "yesterday-u2", Time: day.Add(-time.Hour), UserID: "u2"},
+		{ID: "yesterday-anonymous", Time: day.Add(-30 * time.Minute), Email: "nobody@example.com"},
+	}
+	for _, event := range events {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query *AuditQuery
+		want  []string
+	}{
+		{
+			name:  "all",
+			query: nil,
+			want:  []string{"two-days-ago-u1", "yesterday-u2", "yesterday-anonymous", "today-u1"},
+		},
+		{
+			name:  "one user",
+			query: &AuditQuery{UserID: "u1"},
+			want:  []string{"two-days-ago-u1", "today-u1"},
+		},
+		{
+			name:  "since",
+			query: &AuditQuery{Since: day.Add(-time.Hour)},
+			want:  []string{"yesterday-u2", "yesterday-anonymous", "today-u1"},
+		},
+		{
+			name:  "until is exclusive",
+			query: &AuditQuery{Since: day.Add(-48 * time.Hour), Until: day.Add(-30 * time.Minute)},
+			want:  []string{"two-days-ago-u1", "yesterday-u2"},
+		},
+		{
+			name:  "user and range",
+			query: &AuditQuery{UserID: "u1", Since: day.Add(-24 * time.Hour)},
+			want:  []string{"today-u1"},
+		},
+		{
+			name:  "limit across buckets",
+			query: &AuditQuery{Limit: 2},
+			want:  []string{"two-days-ago-u1", "yesterday-u2"},
+		},
+		{
+			name:  "empty range",
+			query: &AuditQuery{Since: day.Add(-10 * time.Hour), Until: day.Add(-5 * time.Hour)},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := sink.QueryAuditEvents(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := auditIDs(events); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("events %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKVAuditSinkQueryDecodesMetadataAndValues(t *testing.T) {
+	ctx := context.Background()
+	sink, kv := newTestKVAuditSink(t)
+
+	now := time.Now()
+	small := &AuditEvent{ID: "small", Time: now.Add(-time.Minute), UserID: "u1", Action: AuditLoginSucceeded}
+	large := &AuditEvent{
+		ID:      "large",
+		Time:    now.Add(-time.Second),
+		UserID:  "u1",
+		Action:  AuditUserDeleted,
+		Details: map[string]string{"note": strings.Repeat("x", kvMaxMetadataSize)},
+	}
+	for _, event := range []*AuditEvent{small, large} {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kv.mu.Lock()
+	for key, entry := range kv.entries {
+		hasMetadata := entry.metadata != nil
+		if wantMetadata := strings.HasSuffix(key, ":small"); hasMetadata != wantMetadata {
+			t.Errorf("%s has metadata: %v, want %v", key, hasMetadata, wantMetadata)
+		}
+	}
+	kv.mu.Unlock()
+
+	reads := kv.count("GET values")
+	events, err := sink.QueryAuditEvents(ctx, &AuditQuery{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != small.Action || events[1].Details["note"] != large.Details["note"] {
+		t.Fatalf("events %+v, want the small and the large event", events)
+	}
+	// Only the event that did not fit in the metadata is read
+	if n := kv.count("GET values") - reads; n != 1 {
+		t.Fatalf("%d value reads, want 1", n)
+	}
+}
@@ -0,0 +1,177 @@
+// Package authclient provides a typed Go client for the JSON REST API served
+// by cloudflare_auth_sdk.Client.Handler and described by its OpenAPI document.
+//
+// The client is maintained by hand rather than generated from openapi.json.
+// TestClientMatchesSpec checks its requests and decoded responses against
+// the document, so changes to the API must be made in both places.
+//
+// Basic usage:
+//
+//	api := authclient.New("https://auth.example.com", nil)
+//
+//	user, err := api.Register(ctx, "user@example.com", "password")
+//	loginResp, err := api.Login(ctx, "user@example.com", "password")
+//	me, err := api.Me(ctx, loginResp.Token)
+package authclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+)
+
+// Client calls the authentication REST API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the API at baseURL.
+//
+// If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// Register calls POST /auth/register.
+func (c *Client) Register(ctx context.Context, email, password string) (*sdk.UserInfo, error) {
+	var user sdk.UserInfo
+	body := sdk.CredentialsRequest{Email: email, Password: password}
+	if err := c.do(ctx, "Client.Register", http.MethodPost, "/auth/register", "", body, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login calls POST /auth/login.
+func (c *Client) Login(ctx context.Context, email, password string) (*sdk.LoginResponse, error) {
+	var resp sdk.LoginResponse
+	body := sdk.CredentialsRequest{Email: email, Password: password}
+	if err := c.do(ctx, "Client.Login", http.MethodPost, "/auth/login", "", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Refresh calls POST /auth/refresh.
+func (c *Client) Refresh(ctx context.Context, token string) (*sdk.LoginResponse, error) {
+	var resp sdk.LoginResponse
+	if err := c.do(ctx, "Client.Refresh", http.MethodPost, "/auth/refresh", token, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Me calls GET /auth/me.
+func (c *Client) Me(ctx context.Context, token string) (*sdk.UserInfo, error) {
+	var user sdk.UserInfo
+	if err := c.do(ctx, "Client.Me", http.MethodGet, "/auth/me", token, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Logout calls POST /auth/logout.
+func (c *Client) Logout(ctx context.Context, token string) error {
+	return c.do(ctx, "Client.Logout", http.MethodPost, "/auth/logout", token, nil, nil)
+}
+
+// DeleteMe calls DELETE /auth/me.
+func (c *Client) DeleteMe(ctx context.Context, token string) error {
+	return c.do(ctx, "Client.DeleteMe", http.MethodDelete, "/auth/me", token, nil, nil)
+}
+
+// do sends a request and decodes the JSON response into out.
+//
+// Error responses are returned as *sdk.AppError carrying the status code
+// and message from the ErrorResponse envelope.
+func (c *Client) do(ctx context.Context, op, method, path, token string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return sdk.NewAppError(op, err, "failed to encode request", 0)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return sdk.NewAppError(op, err, "failed to create request", 0)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return sdk.NewAppError(op, err, "request failed", 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(op, path, resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return sdk.NewAppError(op, err, "failed to decode response", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// decodeError converts an ErrorResponse into an AppError
+func decodeError(op, path string, resp *http.Response) error {
+	var errResp sdk.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error.Message == "" {
+		errResp.Error.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return sdk.NewAppError(op, statusError(path, resp.StatusCode), errResp.Error.Message, resp.StatusCode)
+}
+
+// statusError maps a response status to the matching SDK sentinel error
+func statusError(path string, status int) error {
+	switch status {
+	case http.StatusBadRequest:
+		return sdk.ErrInvalidInput
+	case http.StatusUnauthorized:
+		if path == "/auth/login" {
+			return sdk.ErrInvalidCredentials
+		}
+		return sdk.ErrInvalidToken
+	case http.StatusForbidden:
+		// Service account tokens have no user profile
+		return sdk.ErrInvalidToken
+	case http.StatusNotFound:
+		return sdk.ErrUserNotFound
+	case http.StatusConflict:
+		return sdk.ErrUserAlreadyExists
+	case http.StatusTooManyRequests:
+		return sdk.ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return sdk.ErrKVOperationFailed
+	default:
+		return fmt.Errorf("unexpected status %d", status)
+	}
+}
@@ -0,0 +1,247 @@
+package authclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+)
+
+// spec is the part of the OpenAPI document the client is checked against
+type spec struct {
+	Paths map[string]map[string]struct {
+		OperationID string                `json:"operationId"`
+		Security    []map[string][]string `json:"security"`
+		RequestBody *struct {
+			Content map[string]struct {
+				Schema struct {
+					Ref string `json:"$ref"`
+				} `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+		Responses map[string]json.RawMessage `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required             []string                   `json:"required"`
+			Properties           map[string]json.RawMessage `json:"properties"`
+			AdditionalProperties *bool                      `json:"additionalProperties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+
+	var s spec
+	if err := json.Unmarshal(sdk.OpenAPISpec(), &s); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+	return &s
+}
+
+// specServer answers every request with status and body after checking the
+// request against the document; it records the operations called
+type specServer struct {
+	t      *testing.T
+	spec   *spec
+	status int
+	body   string
+	called map[string]bool
+}
+
+func (s *specServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	op, ok := s.spec.Paths[r.URL.Path][strings.ToLower(r.Method)]
+	if !ok {
+		s.t.Errorf("%s is not declared in the OpenAPI document", key)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.called[key] = true
+
+	if len(op.Security) > 0 && !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		s.t.Errorf("%s: declared as bearer-authenticated but sent without a token", key)
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	if op.RequestBody == nil {
+		if len(data) != 0 {
+			s.t.Errorf("%s: declares no request body, got %q", key, data)
+		}
+	} else {
+		s.checkBody(key, op.RequestBody.Content["application/json"].Schema.Ref, r, data)
+	}
+
+	if _, ok := op.Responses[strconv.Itoa(s.status)]; !ok {
+		s.t.Fatalf("test bug: %s does not declare status %d", key, s.status)
+	}
+	if s.body != "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(s.status)
+	_, _ = io.WriteString(w, s.body)
+}
+
+// checkBody validates a JSON request body against a component schema
+func (s *specServer) checkBody(key, ref string, r *http.Request, data []byte) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		s.t.Errorf("%s: Content-Type %q, want application/json", key, ct)
+	}
+
+	schema := s.spec.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		s.t.Errorf("%s: request body is not a JSON object: %v", key, err)
+		return
+	}
+	for _, name := range schema.Required {
+		if _, ok := body[name]; !ok {
+			s.t.Errorf("%s: request body lacks required property %q", key, name)
+		}
+	}
+	for name := range body {
+		if _, ok := schema.Properties[name]; !ok && schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+			s.t.Errorf("%s: request body has undeclared property %q", key, name)
+		}
+	}
+}
+
+func newSpecServer(t *testing.T) (*specServer, *Client) {
+	s := &specServer{t: t, spec: loadSpec(t), called: make(map[string]bool)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, New(server.URL, server.Client())
+}
+
+const (
+	userJSON  = `{"id":"u1","email":"alice@example.com"}`
+	loginJSON = `{"token":"t1","expires_at":"2030-01-01T00:00:00Z","user":` + userJSON + `}`
+)
+
+// TestClientMatchesSpec calls every operation with the success response
+// declared in the document and checks the requests and decoded results.
+func TestClientMatchesSpec(t *testing.T) {
+	s, api := newSpecServer(t)
+	ctx := context.Background()
+	wantUser := sdk.UserInfo{ID: "u1", Email: "alice@example.com"}
+
+	s.status, s.body = http.StatusCreated, userJSON
+	user, err := api.Register(ctx, "alice@example.com", "password")
+	if err != nil || *user != wantUser {
+		t.Fatalf("Register = %+v, %v", user, err)
+	}
+
+	s.status, s.body = http.StatusOK, loginJSON
+	resp, err := api.Login(ctx, "alice@example.com", "password")
+	if err != nil || resp.Token != "t1" || resp.User != wantUser || !resp.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Login = %+v, %v", resp, err)
+	}
+
+	resp, err = api.Refresh(ctx, "t1")
+	if err != nil || resp.Token != "t1" {
+		t.Fatalf("Refresh = %+v, %v", resp, err)
+	}
+
+	s.status, s.body = http.StatusOK, userJSON
+	user, err = api.Me(ctx, "t1")
+	if err != nil || *user != wantUser {
+		t.Fatalf("Me = %+v, %v", user, err)
+	}
+
+	s.status, s.body = http.StatusNoContent, ""
+	if err := api.Logout(ctx, "t1"); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if err := api.DeleteMe(ctx, "t1"); err != nil {
+		t.Fatalf("DeleteMe: %v", err)
+	}
+
+	// Every operation except the document itself has a client method
+	for path, ops := range s.spec.Paths {
+		for method := range ops {
+			key := strings.ToUpper(method) + " " + path
+			if path != sdk.OpenAPIPath && !s.called[key] {
+				t.Errorf("%s is declared but the client does not call it", key)
+			}
+		}
+	}
+}
+
+// TestClientErrors checks that every declared error status of every
+// operation is returned as an AppError with the matching sentinel.
+func TestClientErrors(t *testing.T) {
+	s, api := newSpecServer(t)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"POST /auth/register": func() error { _, err := api.Register(ctx, "a@example.com", "p"); return err },
+		"POST /auth/login":    func() error { _, err := api.Login(ctx, "a@example.com", "p"); return err },
+		"POST /auth/refresh":  func() error { _, err := api.Refresh(ctx, "t"); return err },
+		"GET /auth/me":        func() error { _, err := api.Me(ctx, "t"); return err },
+		"POST /auth/logout":   func() error { return api.Logout(ctx, "t") },
+		"DELETE /auth/me":     func() error { return api.DeleteMe(ctx, "t") },
+	}
+
+	for path, ops := range s.spec.Paths {
+		for method, op := range ops {
+			key := strings.ToUpper(method) + " " + path
+			call, ok := calls[key]
+			if !ok {
+				continue
+			}
+			for code := range op.Responses {
+				status, _ := strconv.Atoi(code)
+				if status < 400 {
+					continue
+				}
+
+				s.status = status
+				s.body = `{"error":{"code":` + code + `,"message":"declared failure"}}`
+				err := call()
+
+				var appErr *sdk.AppError
+				if !errors.As(err, &appErr) || appErr.Code != status || appErr.Message != "declared failure" {
+					t.Errorf("%s with %d: got %v, want AppError with the response code and message", key, status, err)
+					continue
+				}
+				if want := wantSentinel(path, status); want != nil && !errors.Is(err, want) {
+					t.Errorf("%s with %d: %v does not wrap %v", key, status, err, want)
+				}
+			}
+		}
+	}
+}
+
+// wantSentinel returns the SDK error a status should map to, or nil if the
+// status has no sentinel
+func wantSentinel(path string, status int) error {
+	switch status {
+	case http.StatusBadRequest:
+		return sdk.ErrInvalidInput
+	case http.StatusUnauthorized:
+		if path == "/auth/login" {
+			return sdk.ErrInvalidCredentials
+		}
+		return sdk.ErrInvalidToken
+	case http.StatusForbidden:
+		return sdk.ErrInvalidToken
+	case http.StatusNotFound:
+		return sdk.ErrUserNotFound
+	case http.StatusConflict:
+		return sdk.ErrUserAlreadyExists
+	case http.StatusTooManyRequests:
+		return sdk.ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return sdk.ErrKVOperationFailed
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerFailureThreshold is the default number of consecutive
+	// failures that open the circuit.
+	defaultBreakerFailureThreshold = 5
+
+	// defaultBreakerCoolDown is the default time the circuit stays open.
+	defaultBreakerCoolDown = 30 * time.Second
+
+	// defaultBreakerHalfOpenRequests is the default number of trial requests
+	// allowed while the circuit is half-open.
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// CircuitState is the state of the KV circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all requests with ErrKVUnavailable.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial requests through.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions contains options for the KV circuit breaker.
+//
+// The breaker opens after FailureThreshold consecutive KV requests fail with
+// network errors, timeouts, 429 or 5xx responses. While open, KV requests
+// fail immediately with ErrKVUnavailable. After CoolDown the breaker lets
+// trial requests through; one success closes it and one failure opens it
+// again.
+type CircuitBreakerOptions struct {
+	FailureThreshold int           // Consecutive failures that open the circuit (default: 5)
+	CoolDown         time.Duration // Time the circuit stays open before trial requests (default: 30s)
+	HalfOpenRequests int           // Concurrent trial requests while half-open (default: 1)
+
+	// OnStateChange is called synchronously after every state change and
+	// must not block.
+	OnStateChange func(from, to CircuitState)
+}
+
+// circuitBreaker tracks KV request outcomes and fails fast while KV is down.
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // Time the circuit last opened
+	inFlight int       // Trial requests running while half-open
+}
+
+func newCircuitBreaker(opts *CircuitBreakerOptions) *circuitBreaker {
+	breakerOpts := *opts
+	if breakerOpts.FailureThreshold == 0 {
+		breakerOpts.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if breakerOpts.CoolDown == 0 {
+		breakerOpts.CoolDown = defaultBreakerCoolDown
+	}
+	if breakerOpts.HalfOpenRequests == 0 {
+		breakerOpts.HalfOpenRequests = defaultBreakerHalfOpenRequests
+	}
+
+	return &circuitBreaker{opts: breakerOpts}
+}
+
+// allow reports whether a request may run; the returned function must be
+// called with the request's outcome
+func (cb *circuitBreaker) allow() (func(error), error) {
+	cb.mu.Lock()
+
+	var changed func()
+	if cb.state == CircuitOpen && !time.Now().Before(cb.openedAt.Add(cb.opts.CoolDown)) {
+		changed = cb.setState(CircuitHalfOpen)
+	}
+
+	switch {
+	case cb.state == CircuitOpen,
+		cb.state == CircuitHalfOpen && cb.inFlight >= cb.opts.HalfOpenRequests:
+		cb.mu.Unlock()
+		notify(changed)
+		return nil, ErrKVUnavailable
+	case cb.state == CircuitHalfOpen:
+		cb.inFlight++
+		cb.mu.Unlock()
+		notify(changed)
+		return cb.doneTrial, nil
+	default:
+		cb.mu.Unlock()
+		notify(changed)
+		return cb.done, nil
+	}
+}
+
+// done records the outcome of a request made while closed
+func (cb *circuitBreaker) done(err error) {
+	failed, counted := breakerOutcome(err)
+	if !counted {
+		return
+	}
+
+	cb.mu.Lock()
+	var changed func()
+	switch {
+	case cb.state != CircuitClosed:
+		// The circuit changed while the request ran; trial requests decide
+	case !failed:
+		cb.failures = 0
+	default:
+		cb.failures++
+		if cb.failures >= cb.opts.FailureThreshold {
+			changed = cb.open()
+		}
+	}
+	cb.mu.Unlock()
+	notify(changed)
+}
+
+// doneTrial records the outcome of a trial request made while half-open
+func (cb *circuitBreaker) doneTrial(err error) {
+	failed, counted := breakerOutcome(err)
+
+	cb.mu.Lock()
+	cb.inFlight--
+	var changed func()
+	if counted && cb.state == CircuitHalfOpen {
+		if failed {
+			changed = cb.open()
+		} else {
+			cb.failures = 0
+			changed = cb.setState(CircuitClosed)
+		}
+	}
+	cb.mu.Unlock()
+	notify(changed)
+}
+
+// open moves the circuit to the open state; cb.mu must be held
+func (cb *circuitBreaker) open() func() {
+	cb.openedAt = time.Now()
+	cb.failures = 0
+	return cb.setState(CircuitOpen)
+}
+
+// setState changes the state and returns the listener notification to run
+// once cb.mu is released; cb.mu must be held
+func (cb *circuitBreaker) setState(state CircuitState) func() {
+	from := cb.state
+	if from == state {
+		return nil
+	}
+	cb.state = state
+
+	onStateChange := cb.opts.OnStateChange
+	if onStateChange == nil {
+		return nil
+	}
+	return func() { onStateChange(from, state) }
+}
+
+func (cb *circuitBreaker) currentState() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && !time.Now().Before(cb.openedAt.Add(cb.opts.CoolDown)) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+func notify(changed func()) {
+	if changed != nil {
+		changed()
+	}
+}
+
+// breakerOutcome classifies a request result; counted is false for results
+// that say nothing about KV health, such as a canceled caller context or a
+// request held back by the rate limiter
+func breakerOutcome(err error) (failed, counted bool) {
+	switch {
+	case err == nil:
+		return false, true
+	case errors.Is(err, context.Canceled), errors.Is(err, errKVThrottled):
+		return false, false
+	case errors.Is(err, context.DeadlineExceeded), isRetryable(err):
+		return true, true
+	default:
+		// Missing keys and other client errors mean KV is reachable
+		return false, true
+	}
+}
+
+// CircuitState returns the state of the KV circuit breaker.
+//
+// It is always CircuitClosed when the breaker is disabled.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.currentState()
+}
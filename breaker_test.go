@@ -0,0 +1,186 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// breakerStep is one action of a circuit breaker test: a request with the
+// given outcome, or the cool-down elapsing when coolDown is set
+type breakerStep struct {
+	outcome  error
+	coolDown bool
+	rejected bool // The request is expected to fail fast
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	failure := apiError(http.StatusServiceUnavailable, nil)
+	notFound := kvNotFoundError()
+	fail := breakerStep{outcome: failure}
+	ok := breakerStep{}
+	rejected := breakerStep{rejected: true}
+	coolDown := breakerStep{coolDown: true}
+
+	tests := []struct {
+		name        string
+		steps       []breakerStep
+		wantState   CircuitState
+		wantChanges []string
+	}{
+		{
+			name:      "stays closed below the threshold",
+			steps:     []breakerStep{fail, fail},
+			wantState: CircuitClosed,
+		},
+		{
+			name:        "opens at the threshold",
+			steps:       []breakerStep{fail, fail, fail, rejected},
+			wantState:   CircuitOpen,
+			wantChanges: []string{"closed->open"},
+		},
+		{
+			name:      "success resets the failure count",
+			steps:     []breakerStep{fail, fail, ok, fail, fail},
+			wantState: CircuitClosed,
+		},
+		{
+			name: "ignores outcomes unrelated to KV health",
+			steps: []breakerStep{
+				fail, fail,
+				{outcome: context.Canceled},
+				{outcome: errKVThrottled},
+				{outcome: notFound},
+				{outcome: ErrKeyNotFound},
+			},
+			wantState: CircuitClosed,
+		},
+		{
+			name:        "counts timeouts",
+			steps:       []breakerStep{{outcome: context.DeadlineExceeded}, fail, fail},
+			wantState:   CircuitOpen,
+			wantChanges: []string{"closed->open"},
+		},
+		{
+			name:        "trial success closes",
+			steps:       []breakerStep{fail, fail, fail, coolDown, ok, ok},
+			wantState:   CircuitClosed,
+			wantChanges: []string{"closed->open", "open->half-open", "half-open->closed"},
+		},
+		{
+			name:        "trial failure reopens",
+			steps:       []breakerStep{fail, fail, fail, coolDown, fail, rejected},
+			wantState:   CircuitOpen,
+			wantChanges: []string{"closed->open", "open->half-open", "half-open->open"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []string
+			cb := newCircuitBreaker(&CircuitBreakerOptions{
+				FailureThreshold: 3,
+				CoolDown:         time.Hour,
+				OnStateChange: func(from, to CircuitState) {
+					changes = append(changes, from.String()+"->"+to.String())
+				},
+			})
+
+			for i, step := range tt.steps {
+				if step.coolDown {
+					cb.mu.Lock()
+					cb.openedAt = cb.openedAt.Add(-cb.opts.CoolDown)
+					cb.mu.Unlock()
+					continue
+				}
+
+				done, err := cb.allow()
+				if step.rejected {
+					if !errors.Is(err, ErrKVUnavailable) {
+						t.Fatalf("step %d: allow = %v, want ErrKVUnavailable", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d: allow = %v", i, err)
+				}
+				done(step.outcome)
+			}
+
+			if state := cb.currentState(); state != tt.wantState {
+				t.Errorf("state %v, want %v", state, tt.wantState)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("state changes %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenLimitsTrials(t *testing.T) {
+	cb := newCircuitBreaker(&CircuitBreakerOptions{FailureThreshold: 1, CoolDown: time.Hour, HalfOpenRequests: 2})
+
+	done, _ := cb.allow()
+	done(apiError(http.StatusBadGateway, nil))
+	if state := cb.currentState(); state != CircuitOpen {
+		t.Fatalf("state %v, want open", state)
+	}
+
+	cb.mu.Lock()
+	cb.openedAt = cb.openedAt.Add(-time.Hour)
+	cb.mu.Unlock()
+	if state := cb.currentState(); state != CircuitHalfOpen {
+		t.Fatalf("state %v after the cool-down, want half-open", state)
+	}
+
+	first, err1 := cb.allow()
+	second, err2 := cb.allow()
+	if err1 != nil || err2 != nil {
+		t.Fatalf("trial requests rejected: %v, %v", err1, err2)
+	}
+	if _, err := cb.allow(); !errors.Is(err, ErrKVUnavailable) {
+		t.Fatalf("third concurrent trial = %v, want ErrKVUnavailable", err)
+	}
+
+	// A canceled trial frees its slot without deciding
+	first(context.Canceled)
+	third, err := cb.allow()
+	if err != nil {
+		t.Fatalf("trial after a canceled one rejected: %v", err)
+	}
+	second(nil)
+	third(apiError(http.StatusServiceUnavailable, nil)) // Arrives after the circuit closed
+
+	if state := cb.currentState(); state != CircuitClosed {
+		t.Fatalf("state %v, want closed", state)
+	}
+}
+
+func TestClientCircuitBreakerFailsFast(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{
+		CircuitBreaker: &CircuitBreakerOptions{FailureThreshold: 2, CoolDown: time.Hour},
+	})
+	kv.fail = func(*http.Request) int { return http.StatusServiceUnavailable }
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.KVGet(ctx, "item:1"); !errors.Is(err, ErrKVOperationFailed) {
+			t.Fatalf("KVGet %d = %v, want ErrKVOperationFailed", i, err)
+		}
+	}
+	if state := client.CircuitState(); state != CircuitOpen {
+		t.Fatalf("CircuitState = %v, want open", state)
+	}
+
+	_, err := client.KVGet(ctx, "item:1")
+	var appErr *AppError
+	if !errors.Is(err, ErrKVUnavailable) || !errors.As(err, &appErr) || appErr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("KVGet with the circuit open = %v, want a 503 wrapping ErrKVUnavailable", err)
+	}
+	if n := kv.count("GET values"); n != 2 {
+		t.Fatalf("%d requests reached KV, want 2", n)
+	}
+}
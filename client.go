@@ -18,7 +18,10 @@ package cloudflare_auth_sdk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	cloudflare "github.com/cloudflare/cloudflare-go/v6"
@@ -26,6 +29,8 @@ import (
 	"github.com/cloudflare/cloudflare-go/v6/option"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -36,6 +41,33 @@ type Client struct {
 	namespaceID string
 	jwtSecret   []byte
 	jwtExpiry   time.Duration
+
+	serviceTokenTTL time.Duration
+
+	sessionIdleTimeout   time.Duration
+	sessionTouchInterval time.Duration
+
+	userCache *userCache
+	kvCache   *kvCache
+	retry     *RetryPolicy
+	breaker   *circuitBreaker
+
+	rateLimiter     *kvRateLimiter
+	loginLimiter    *RateLimiter
+	registerLimiter *RateLimiter
+
+	tracer  trace.Tracer
+	metrics MetricsRecorder
+	logger  *slog.Logger
+
+	auditSink AuditSink
+	hooks     hooks
+
+	kvSchemas sync.Map // reflect.Type -> int, see RegisterKVSchema
+
+	// now returns the current time for token and session checks; tests
+	// replace it to control the clock
+	now func() time.Time
 }
 
 // NewClient creates a new SDK client with the provided options.
@@ -59,53 +91,154 @@ func NewClient(opts *ClientOptions) (*Client, error) {
 	}
 
 	// Create Cloudflare client
-	var cfClient *cloudflare.Client
+	var cfOpts []option.RequestOption
 	if opts.APIToken != "" {
-		cfClient = cloudflare.NewClient(
-			option.WithAPIToken(opts.APIToken),
-		)
+		cfOpts = append(cfOpts, option.WithAPIToken(opts.APIToken))
 	} else {
-		cfClient = cloudflare.NewClient(
+		cfOpts = append(cfOpts,
 			option.WithAPIKey(opts.APIKey),
 			option.WithAPIEmail(opts.Email),
 		)
 	}
 
+	// The retry policy replaces the built-in retries of the Cloudflare client.
+	// With rate limiting the default policy is used, since built-in retries
+	// would bypass the limiter
+	var retry *RetryPolicy
+	if opts.Retry != nil || opts.KVRateLimit != nil {
+		var policy RetryPolicy
+		if opts.Retry != nil {
+			policy = *opts.Retry
+		}
+		policy = policy.withDefaults()
+		retry = &policy
+		cfOpts = append(cfOpts, option.WithMaxRetries(0))
+	}
+
+	cfClient := cloudflare.NewClient(cfOpts...)
+
+	var breaker *circuitBreaker
+	if opts.CircuitBreaker != nil {
+		breaker = newCircuitBreaker(opts.CircuitBreaker)
+	}
+
+	var rateLimiter *kvRateLimiter
+	if opts.KVRateLimit != nil {
+		rateLimiter = newKVRateLimiter(opts.KVRateLimit)
+	}
+
+	var metrics MetricsRecorder = nopMetrics{}
+	if opts.Metrics != nil {
+		metrics = opts.Metrics
+	}
+
 	// Set default JWT expiration
 	jwtExpiry := time.Duration(opts.JWTExpirationHours) * time.Hour
 	if jwtExpiry == 0 {
 		jwtExpiry = 24 * time.Hour
 	}
 
-	return &Client{
-		cfClient:    cfClient,
-		accountID:   opts.AccountID,
-		namespaceID: opts.NamespaceID,
-		jwtSecret:   []byte(opts.JWTSecret),
-		jwtExpiry:   jwtExpiry,
-	}, nil
+	serviceTokenTTL := opts.ServiceTokenTTL
+	if serviceTokenTTL == 0 {
+		serviceTokenTTL = defaultServiceTokenTTL
+	}
+
+	// Set default session touch interval
+	sessionTouchInterval := opts.SessionTouchInterval
+	if opts.SessionIdleTimeout > 0 && sessionTouchInterval == 0 {
+		sessionTouchInterval = defaultSessionTouchInterval
+		if half := opts.SessionIdleTimeout / 2; sessionTouchInterval > half {
+			sessionTouchInterval = half
+		}
+	}
+
+	// Set up the user lookup cache
+	var cache *userCache
+	if opts.UserCacheSize > 0 {
+		ttl := opts.UserCacheTTL
+		if ttl == 0 {
+			ttl = defaultUserCacheTTL
+		}
+		cache = newUserCache(opts.UserCacheSize, ttl)
+	}
+
+	// Set up the KV read cache
+	var kvc *kvCache
+	if opts.KVCache != nil {
+		kvc = newKVCache(opts.KVCache)
+	}
+
+	client := &Client{
+		cfClient:             cfClient,
+		accountID:            opts.AccountID,
+		namespaceID:          opts.NamespaceID,
+		jwtSecret:            []byte(opts.JWTSecret),
+		jwtExpiry:            jwtExpiry,
+		serviceTokenTTL:      serviceTokenTTL,
+		sessionIdleTimeout:   opts.SessionIdleTimeout,
+		sessionTouchInterval: sessionTouchInterval,
+		userCache:            cache,
+		kvCache:              kvc,
+		retry:                retry,
+		breaker:              breaker,
+		rateLimiter:          rateLimiter,
+		tracer:               newTracer(opts.TracerProvider),
+		metrics:              metrics,
+		logger:               newLogger(opts.Logger),
+		now:                  time.Now,
+	}
+
+	client.loginLimiter = client.newAuthLimiter(opts.LoginRateLimit, "login")
+	client.registerLimiter = client.newAuthLimiter(opts.RegisterRateLimit, "register")
+
+	return client, nil
 }
 
 // Register creates a new user account.
 //
 // The password will be securely hashed using bcrypt before storage.
 // Returns the created user information or an error if registration fails.
-func (c *Client) Register(ctx context.Context, email, password string) (*User, error) {
+func (c *Client) Register(ctx context.Context, email, password string) (_ *User, err error) {
 	const op = "Client.Register"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID string
+	defer func() {
+		c.metrics.RecordRegistration(ctx, authOutcome(err))
+		c.logAuth(ctx, slog.LevelInfo, op, userID, start, err)
+		endSpan(span, err)
+	}()
 
 	if email == "" || password == "" {
 		return nil, NewAppError(op, ErrInvalidInput, "email and password are required", 400)
 	}
 
-	// Check if user already exists
+	// Throttle registrations per client IP before doing any work; without
+	// an IP, all callers would share one counter
+	if ip, ok := ClientIPFromContext(ctx); ok && c.registerLimiter != nil {
+		if _, err := c.registerLimiter.Allow(ctx, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, hook := range c.hooks.beforeRegister {
+		if err := hook(ctx, email); err != nil {
+			return nil, hookError(op, err)
+		}
+	}
+
+	// Check if user already exists; fail closed if storage cannot answer
 	userKey := getUserKey(email)
-	existingData, _ := c.kvGet(ctx, userKey)
-	if existingData != nil {
+	_, err = c.kvGet(ctx, userKey)
+	if err == nil {
 		return nil, NewAppError(op, ErrUserAlreadyExists, "user already exists", 409)
 	}
+	if !isKVNotFound(err) {
+		return nil, kvError(op, err, "failed to check for existing user")
+	}
 
 	// Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passwordHash, err := c.hashPassword(ctx, password)
 	if err != nil {
 		return nil, NewAppError(op, err, "failed to hash password", 500)
 	}
@@ -124,6 +257,13 @@ func (c *Client) Register(ctx context.Context, email, password string) (*User, e
 	if err := c.saveUser(ctx, user); err != nil {
 		return nil, err
 	}
+	userID = user.ID
+
+	c.audit(ctx, &AuditEvent{Action: AuditUserRegistered, UserID: user.ID, Email: user.Email})
+
+	for _, hook := range c.hooks.afterRegister {
+		hook(ctx, user)
+	}
 
 	return user, nil
 }
@@ -131,75 +271,324 @@ func (c *Client) Register(ctx context.Context, email, password string) (*User, e
 // Login authenticates a user and returns a JWT token.
 //
 // Returns login response with token and user info, or an error if authentication fails.
-func (c *Client) Login(ctx context.Context, email, password string) (*LoginResponse, error) {
+// Unknown emails fail with ErrInvalidCredentials like wrong passwords, after
+// the same bcrypt work, so callers cannot tell which emails are registered.
+// Metrics and the audit trail still record them as user_not_found.
+func (c *Client) Login(ctx context.Context, email, password string) (_ *LoginResponse, err error) {
 	const op = "Client.Login"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID, sessionID string
+	unknownUser := false
+	defer func() {
+		outcome := authOutcome(err)
+		if unknownUser {
+			outcome = OutcomeUserNotFound
+		}
+		c.metrics.RecordLogin(ctx, outcome)
+		c.logAuth(ctx, slog.LevelInfo, op, userID, start, err)
+		if err == nil {
+			c.audit(ctx, &AuditEvent{Action: AuditLoginSucceeded, UserID: userID, Email: email, SessionID: sessionID})
+		} else if !errors.Is(err, ErrInvalidInput) {
+			c.audit(ctx, &AuditEvent{Action: AuditLoginFailed, UserID: userID, Email: email, Reason: outcome})
+		}
+		endSpan(span, err)
+	}()
 
 	if email == "" || password == "" {
 		return nil, NewAppError(op, ErrInvalidInput, "email and password are required", 400)
 	}
 
+	// Refuse attempts once too many have failed, before doing any work
+	throttleID, throttled := loginThrottleID(ctx, email)
+	if throttled && c.loginLimiter != nil {
+		if _, err := c.loginLimiter.check(ctx, op, throttleID, false, c.now()); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get user
 	user, err := c.getUserByEmail(ctx, email)
 	if err != nil {
-		return nil, NewAppError(op, ErrUserNotFound, "user not found", 404)
+		if !IsUserNotFound(err) {
+			return nil, err
+		}
+		// Spend the time a password check takes and answer as for a
+		// wrong password
+		unknownUser = true
+		_ = c.comparePassword(ctx, string(dummyPasswordHash()), password)
+		c.recordLoginFailure(ctx, throttleID)
+		return nil, NewAppError(op, ErrInvalidCredentials, "invalid credentials", 401)
 	}
+	userID = user.ID
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	if err := c.comparePassword(ctx, user.PasswordHash, password); err != nil {
+		c.recordLoginFailure(ctx, throttleID)
 		return nil, NewAppError(op, ErrInvalidCredentials, "invalid credentials", 401)
 	}
 
-	// Generate JWT token
-	expiresAt := time.Now().Add(c.jwtExpiry)
-	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+	// Let hooks veto the login or add custom claims
+	claims := &Claims{UserID: user.ID, Email: user.Email, Extra: map[string]interface{}{}}
+	for _, hook := range c.hooks.beforeLogin {
+		if err := hook(ctx, user, claims); err != nil {
+			return nil, hookError(op, err)
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(c.jwtSecret)
+	// Generate JWT token; with sliding sessions the token expiry is the
+	// session's absolute maximum
+	now := c.now()
+	expiresAt := now.Add(c.jwtExpiry)
+	if c.sessionIdleTimeout > 0 {
+		session, err := c.createSession(ctx, user, now, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		sessionID = session.ID
+	}
+
+	resp, err := c.issueToken(user, sessionID, claims.Extra, now, expiresAt)
 	if err != nil {
-		return nil, NewAppError(op, err, "failed to generate token", 500)
+		return nil, err
 	}
 
-	return &LoginResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt,
-		User: UserInfo{
-			ID:    user.ID,
-			Email: user.Email,
-		},
-	}, nil
+	for _, hook := range c.hooks.afterLogin {
+		hook(ctx, user, resp)
+	}
+
+	return resp, nil
+}
+
+// RefreshToken validates a token and issues a new one for the same user.
+//
+// Without sliding sessions the new token gets a full JWTExpirationHours
+// lifetime. With sliding sessions it stays bound to the same session and
+// keeps the session's absolute expiry.
+func (c *Client) RefreshToken(ctx context.Context, tokenString string) (_ *LoginResponse, err error) {
+	const op = "Client.RefreshToken"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID string
+	defer func() {
+		c.logAuth(ctx, slog.LevelInfo, op, userID, start, err)
+		endSpan(span, err)
+	}()
+
+	info, err := c.ValidateSession(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if info.ServiceAccount != nil {
+		return nil, NewAppError(op, ErrInvalidToken, "service account tokens cannot be refreshed", 400)
+	}
+	userID = info.User.ID
+
+	now := c.now()
+	expiresAt := now.Add(c.jwtExpiry)
+	if info.SessionID != "" {
+		expiresAt = info.Claims.ExpiresAt.Time
+	}
+
+	return c.issueToken(info.User, info.SessionID, info.Claims.Extra, now, expiresAt)
+}
+
+// Logout ends the session referenced by the token.
+//
+// Tokens issued without sliding sessions are stateless and remain valid
+// until they expire; for those Logout only checks the token.
+func (c *Client) Logout(ctx context.Context, tokenString string) (err error) {
+	const op = "Client.Logout"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID string
+	defer func() {
+		c.logAuth(ctx, slog.LevelInfo, op, userID, start, err)
+		endSpan(span, err)
+	}()
+
+	claims, err := c.parseToken(tokenString)
+	if err != nil {
+		return err
+	}
+	userID = claims.UserID
+
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	if err := c.kvDelete(ctx, getSessionKey(claims.SessionID)); err != nil {
+		return kvError(op, err, "failed to end session")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditSessionRevoked, UserID: claims.UserID, SessionID: claims.SessionID})
+
+	return nil
 }
 
 // ValidateToken validates a JWT token and returns the user information.
 //
+// When sliding sessions are enabled the session is checked for idle timeout
+// and extended on activity. Use ValidateSession to also obtain the remaining
+// session lifetime.
+//
 // Returns user info if the token is valid, or an error if validation fails.
-func (c *Client) ValidateToken(ctx context.Context, tokenString string) (*User, error) {
+// Service account tokens are rejected, since they have no user.
+func (c *Client) ValidateToken(ctx context.Context, tokenString string) (_ *User, err error) {
 	const op = "Client.ValidateToken"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	info, err := c.ValidateSession(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if info.User == nil {
+		return nil, NewAppError(op, ErrInvalidToken, "token belongs to a service account", 401)
+	}
+
+	return info.User, nil
+}
+
+// ValidateSession validates a JWT token and returns the user together with
+// the token claims and the remaining session lifetime.
+//
+// When sliding sessions are enabled (see ClientOptions.SessionIdleTimeout),
+// the session's last activity is refreshed and the returned expiry is the
+// earlier of the idle deadline and the absolute token expiry.
+//
+// Tokens issued by ExchangeClientCredentials are not bound to sessions; for
+// them SessionInfo.ServiceAccount is set instead of SessionInfo.User.
+func (c *Client) ValidateSession(ctx context.Context, tokenString string) (_ *SessionInfo, err error) {
+	const op = "Client.ValidateSession"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID string
+	defer func() {
+		c.metrics.RecordTokenValidation(ctx, authOutcome(err))
+		c.logAuth(ctx, slog.LevelDebug, op, userID, start, err)
+		endSpan(span, err)
+	}()
 
 	claims, err := c.parseToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	userID = claims.UserID
+
+	now := c.now()
+	info := &SessionInfo{
+		Claims:    claims,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
 
-	return c.GetUserByID(ctx, claims.UserID)
+	if claims.ServiceAccount {
+		if info.ServiceAccount, err = c.validateServiceAccount(ctx, op, claims.UserID); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := c.validateUserSession(ctx, info, now); err != nil {
+			return nil, err
+		}
+	}
+	info.Remaining = info.ExpiresAt.Sub(now)
+
+	if err := c.runTokenValidatedHooks(ctx, op, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// VerifySession is the claims-only counterpart of ValidateSession: it
+// verifies the token with VerifyTokenContext, without KV access, and then
+// runs the OnTokenValidated hooks.
+//
+// Only Claims, ExpiresAt and Remaining are set in the returned SessionInfo;
+// User and ServiceAccount are nil. The middleware and the grpcauth
+// interceptors use it for ClaimsOnly and FallbackToClaims.
+func (c *Client) VerifySession(ctx context.Context, tokenString string) (*SessionInfo, error) {
+	claims, err := c.VerifyTokenContext(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SessionInfo{Claims: claims}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+		info.Remaining = info.ExpiresAt.Sub(c.now())
+	}
+	if err := c.runTokenValidatedHooks(ctx, "Client.VerifySession", info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// runTokenValidatedHooks runs the OnTokenValidated hooks for a token
+func (c *Client) runTokenValidatedHooks(ctx context.Context, op string, info *SessionInfo) error {
+	for _, hook := range c.hooks.tokenValidated {
+		if err := hook(ctx, info); err != nil {
+			return hookError(op, err)
+		}
+	}
+	return nil
+}
+
+// validateUserSession checks the sliding session of a user token and loads
+// the user into info
+func (c *Client) validateUserSession(ctx context.Context, info *SessionInfo, now time.Time) error {
+	claims := info.Claims
+	if c.sessionIdleTimeout > 0 {
+		session, err := c.touchSession(ctx, claims, now)
+		if err != nil {
+			return err
+		}
+		info.SessionID = session.ID
+		info.ExpiresAt = session.deadline(c.sessionIdleTimeout)
+	}
+
+	user, err := c.lookupUser(ctx, claims.UserID, now)
+	if err != nil {
+		return err
+	}
+
+	info.User = user
+	return nil
+}
+
+// VerifyToken checks a token's signature and registered claims and returns
+// its claims without any KV access.
+//
+// Unlike ValidateToken it does not confirm that the user still exists, and
+// it does not check or extend sliding sessions, so a token stays usable
+// until it expires even after logout or user deletion.
+func (c *Client) VerifyToken(tokenString string) (*Claims, error) {
+	return c.VerifyTokenContext(context.Background(), tokenString)
+}
+
+// VerifyTokenContext is like VerifyToken but records the token validation
+// metric with ctx, so that recorders see the caller's trace and baggage.
+func (c *Client) VerifyTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := c.parseToken(tokenString)
+	c.metrics.RecordTokenValidation(ctx, authOutcome(err))
+	return claims, err
 }
 
 // GetUserByID retrieves user information by user ID.
-func (c *Client) GetUserByID(ctx context.Context, userID string) (*User, error) {
+func (c *Client) GetUserByID(ctx context.Context, userID string) (_ *User, err error) {
 	const op = "Client.GetUserByID"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
 
 	// Get email from ID mapping
 	idKey := getUserIDKey(userID)
 	emailData, err := c.kvGet(ctx, idKey)
 	if err != nil {
-		return nil, NewAppError(op, ErrUserNotFound, "user not found", 404)
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrUserNotFound, "user not found", 404)
+		}
+		return nil, kvError(op, err, "failed to get user")
 	}
 
 	return c.getUserByEmail(ctx, string(emailData))
@@ -210,30 +599,101 @@ func (c *Client) GetUserByEmail(ctx context.Context, email string) (*User, error
 	return c.getUserByEmail(ctx, email)
 }
 
-// DeleteUser deletes a user account.
-func (c *Client) DeleteUser(ctx context.Context, email string) error {
+// DeleteUser deletes a user account and revokes its API keys.
+func (c *Client) DeleteUser(ctx context.Context, email string) (err error) {
 	const op = "Client.DeleteUser"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	var userID string
+	defer func() {
+		c.logAuth(ctx, slog.LevelInfo, op, userID, start, err)
+		endSpan(span, err)
+	}()
 
 	user, err := c.getUserByEmail(ctx, email)
 	if err != nil {
 		return err
 	}
+	userID = user.ID
+
+	for _, hook := range c.hooks.beforeDeleteUser {
+		if err := hook(ctx, user); err != nil {
+			return hookError(op, err)
+		}
+	}
+
+	// Revoke API keys first, so none outlives the user
+	keys, err := c.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := c.RevokeAPIKey(ctx, user.ID, key.ID); err != nil && !IsInvalidAPIKey(err) {
+			return err
+		}
+	}
 
 	// Delete user data
 	userKey := getUserKey(email)
 	if err := c.kvDelete(ctx, userKey); err != nil {
-		return NewAppError(op, err, "failed to delete user", 500)
+		return kvError(op, err, "failed to delete user")
 	}
 
 	// Delete ID mapping
 	idKey := getUserIDKey(user.ID)
 	if err := c.kvDelete(ctx, idKey); err != nil {
-		return NewAppError(op, err, "failed to delete user ID mapping", 500)
+		return kvError(op, err, "failed to delete user ID mapping")
+	}
+
+	c.InvalidateUser(user.ID)
+
+	c.audit(ctx, &AuditEvent{Action: AuditUserDeleted, UserID: user.ID, Email: user.Email})
+
+	for _, hook := range c.hooks.afterDeleteUser {
+		hook(ctx, user)
 	}
 
 	return nil
 }
 
+// issueToken signs a JWT token for the user
+func (c *Client) issueToken(user *User, sessionID string, extra map[string]interface{}, now, expiresAt time.Time) (*LoginResponse, error) {
+	const op = "Client.issueToken"
+
+	claims := &Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Extra:     extra,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	tokenString, err := c.signToken(op, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:     tokenString,
+		ExpiresAt: expiresAt,
+		User:      user.ToUserInfo(),
+	}, nil
+}
+
+// signToken signs claims into a JWT token
+func (c *Client) signToken(op string, claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(c.jwtSecret)
+	if err != nil {
+		return "", NewAppError(op, err, "failed to generate token", 500)
+	}
+	return tokenString, nil
+}
+
 // parseToken parses and validates a JWT token
 func (c *Client) parseToken(tokenString string) (*Claims, error) {
 	const op = "Client.parseToken"
@@ -243,7 +703,7 @@ func (c *Client) parseToken(tokenString string) (*Claims, error) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return c.jwtSecret, nil
-	})
+	}, jwt.WithTimeFunc(c.now))
 
 	if err != nil {
 		return nil, NewAppError(op, err, "invalid token", 401)
@@ -264,10 +724,38 @@ func (c *Client) getUserByEmail(ctx context.Context, email string) (*User, error
 	userKey := getUserKey(email)
 	userData, err := c.kvGet(ctx, userKey)
 	if err != nil {
-		return nil, NewAppError(op, ErrUserNotFound, "user not found", 404)
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrUserNotFound, "user not found", 404)
+		}
+		return nil, kvError(op, err, "failed to get user")
 	}
 
-	return userFromJSON(userData)
+	user, err := userFromJSON(userData)
+	if err != nil {
+		return nil, NewAppError(op, err, "failed to parse user", 500)
+	}
+
+	return user, nil
+}
+
+// lookupUser retrieves a user by ID, using the user cache when enabled
+func (c *Client) lookupUser(ctx context.Context, userID string, now time.Time) (*User, error) {
+	if c.userCache == nil {
+		return c.GetUserByID(ctx, userID)
+	}
+
+	if user, ok := c.userCache.get(userID, now); ok {
+		return user, nil
+	}
+
+	generation := c.userCache.generation.Load()
+	user, err := c.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.userCache.add(user, generation, now)
+	return user, nil
 }
 
 // saveUser saves a user to KV storage
@@ -280,19 +768,49 @@ func (c *Client) saveUser(ctx context.Context, user *User) error {
 	}
 
 	userKey := getUserKey(user.Email)
-	if err := c.kvSet(ctx, userKey, userData); err != nil {
-		return NewAppError(op, err, "failed to save user", 500)
+	if err := c.kvSet(ctx, userKey, userData, nil); err != nil {
+		return kvError(op, err, "failed to save user")
 	}
 
 	// Save ID mapping
 	idKey := getUserIDKey(user.ID)
-	if err := c.kvSet(ctx, idKey, []byte(user.Email)); err != nil {
-		return NewAppError(op, err, "failed to save user ID mapping", 500)
+	if err := c.kvSet(ctx, idKey, []byte(user.Email), nil); err != nil {
+		return kvError(op, err, "failed to save user ID mapping")
 	}
 
+	c.InvalidateUser(user.ID)
+
 	return nil
 }
 
+// dummyPasswordHash returns a bcrypt hash that Login compares passwords
+// against for unknown emails
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashPassword hashes a password with bcrypt
+func (c *Client) hashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := c.startSpan(ctx, "bcrypt.GenerateFromPassword")
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	endSpan(span, err)
+	return hash, err
+}
+
+// comparePassword checks a password against a bcrypt hash
+func (c *Client) comparePassword(ctx context.Context, hash, password string) error {
+	_, span := c.startSpan(ctx, "bcrypt.CompareHashAndPassword")
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		// A wrong password is an expected outcome, not a failed operation
+		span.End()
+		return err
+	}
+	endSpan(span, err)
+	return err
+}
+
 // Helper functions for key generation
 func getUserKey(email string) string {
 	return fmt.Sprintf("user:email:%s", email)
@@ -304,31 +822,124 @@ func getUserIDKey(userID string) string {
 
 // KV operation wrappers
 func (c *Client) kvGet(ctx context.Context, key string) ([]byte, error) {
-	resp, err := c.cfClient.KV.Namespaces.Values.Get(ctx, c.namespaceID, key,
-		kv.NamespaceValueGetParams{
-			AccountID: cloudflare.F(c.accountID),
+	if c.kvCache != nil {
+		return c.kvCache.get(ctx, key, func(ctx context.Context) ([]byte, error) {
+			return c.kvFetch(ctx, key)
 		})
-	if err != nil {
-		return nil, err
 	}
-	defer resp.Body.Close()
+	return c.kvFetch(ctx, key)
+}
+
+func (c *Client) kvFetch(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := c.kvCall(ctx, "KV.Get", KVClassRead, kvKeyAttr(key), func(ctx context.Context) error {
+		resp, err := c.cfClient.KV.Namespaces.Values.Get(ctx, c.namespaceID, key,
+			kv.NamespaceValueGetParams{
+				AccountID: cloudflare.F(c.accountID),
+			})
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	return readAll(resp.Body)
+		value, err = readAll(resp.Body)
+		return err
+	})
+	return value, err
 }
 
-func (c *Client) kvSet(ctx context.Context, key string, value []byte) error {
-	_, err := c.cfClient.KV.Namespaces.Values.Update(ctx, c.namespaceID, key,
-		kv.NamespaceValueUpdateParams{
-			AccountID: cloudflare.F(c.accountID),
-			Value:     cloudflare.F(string(value)),
-		})
+func (c *Client) kvSet(ctx context.Context, key string, value []byte, opts *KVWriteOptions) error {
+	params := kv.NamespaceValueUpdateParams{
+		AccountID: cloudflare.F(c.accountID),
+		Value:     cloudflare.F(string(value)),
+	}
+
+	if opts != nil {
+		if err := validateKVExpiration(opts.ExpirationTTL, opts.Expiration, time.Now()); err != nil {
+			return err
+		}
+		if opts.ExpirationTTL > 0 {
+			params.ExpirationTTL = cloudflare.F(float64(opts.ExpirationTTL))
+		} else if !opts.Expiration.IsZero() {
+			params.Expiration = cloudflare.F(float64(opts.Expiration.Unix()))
+		}
+		if opts.Metadata != nil {
+			metadata, err := encodeKVMetadata(opts.Metadata)
+			if err != nil {
+				return err
+			}
+			// Sent as a JSON string; the form encoder would flatten maps and structs
+			params.Metadata = cloudflare.F[any](string(metadata))
+		}
+	}
+
+	err := c.kvCall(ctx, "KV.Put", KVClassWrite, kvKeyAttr(key), func(ctx context.Context) error {
+		_, err := c.cfClient.KV.Namespaces.Values.Update(ctx, c.namespaceID, key, params)
+		return err
+	})
+	c.invalidateKV(key)
 	return err
 }
 
 func (c *Client) kvDelete(ctx context.Context, key string) error {
-	_, err := c.cfClient.KV.Namespaces.Values.Delete(ctx, c.namespaceID, key,
-		kv.NamespaceValueDeleteParams{
-			AccountID: cloudflare.F(c.accountID),
-		})
+	err := c.kvCall(ctx, "KV.Delete", KVClassWrite, kvKeyAttr(key), func(ctx context.Context) error {
+		_, err := c.cfClient.KV.Namespaces.Values.Delete(ctx, c.namespaceID, key,
+			kv.NamespaceValueDeleteParams{
+				AccountID: cloudflare.F(c.accountID),
+			})
+		return err
+	})
+	c.invalidateKV(key)
+	return err
+}
+
+func (c *Client) kvDeleteBulk(ctx context.Context, keys []string) error {
+	err := c.kvCall(ctx, "KV.BulkDelete", KVClassBulk, attrKVKeyCount.Int(len(keys)), func(ctx context.Context) error {
+		_, err := c.cfClient.KV.Namespaces.Keys.BulkDelete(ctx, c.namespaceID,
+			kv.NamespaceKeyBulkDeleteParams{
+				AccountID: cloudflare.F(c.accountID),
+				Body:      keys,
+			})
+		return err
+	})
+	c.invalidateKV(keys...)
 	return err
 }
+
+// kvCall runs a KV request through the circuit breaker, the retry policy
+// and the rate limiter, in a client span with the given name
+func (c *Client) kvCall(ctx context.Context, name string, class KVOperationClass, attr attribute.KeyValue, fn func(context.Context) error) (err error) {
+	ctx, span := c.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attr))
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordKVRequest(ctx, name, kvOutcome(err), time.Since(start))
+		c.logKV(ctx, name, class, slog.Any(string(attr.Key), attr.Value.AsInterface()), start, err)
+		if isKVNotFound(err) {
+			// Callers treat a missing key as a regular outcome
+			span.End()
+			return
+		}
+		endSpan(span, err)
+	}()
+
+	if c.breaker == nil {
+		return c.kvRetry(ctx, class, fn)
+	}
+
+	done, err := c.breaker.allow()
+	if err != nil {
+		return err
+	}
+
+	err = c.kvRetry(ctx, class, fn)
+	done(err)
+	return err
+}
+
+// invalidateKV drops keys from the KV cache; it runs after every write,
+// successful or not, since a failed write may still have been applied
+func (c *Client) invalidateKV(keys ...string) {
+	if c.kvCache != nil {
+		c.kvCache.invalidate(keys...)
+	}
+}
@@ -0,0 +1,97 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// failUserEmailLookups makes reads of "user:email:" keys fail with status
+func failUserEmailLookups(kv *fakeKV, status int) {
+	kv.fail = func(r *http.Request) int {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/values/user:email:") {
+			return status
+		}
+		return 0
+	}
+}
+
+func TestUserLookupsFailClosed(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantCode int
+	}{
+		{name: "server error", status: http.StatusInternalServerError, wantCode: http.StatusBadGateway},
+		{name: "overloaded", status: http.StatusServiceUnavailable, wantCode: http.StatusServiceUnavailable},
+		{name: "missing namespace", status: http.StatusNotFound, wantCode: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client, kv := newTestClient(t, &ClientOptions{Retry: &RetryPolicy{MaxAttempts: 1}})
+			if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+				t.Fatal(err)
+			}
+			failUserEmailLookups(kv, tt.status)
+
+			var appErr *AppError
+			_, err := client.Register(ctx, "bob@example.com", "password")
+			if !IsKVOperationFailed(err) || !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Fatalf("Register = %v, want a KV failure with code %d", err, tt.wantCode)
+			}
+			if keys := kv.keys("user:"); len(keys) != 2 {
+				t.Fatalf("keys %v after a failed Register, want only alice's", keys)
+			}
+
+			_, err = client.Login(ctx, "alice@example.com", "password")
+			if !IsKVOperationFailed(err) || IsUserNotFound(err) || !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Fatalf("Login = %v, want a KV failure with code %d", err, tt.wantCode)
+			}
+			if _, err := client.GetUserByEmail(ctx, "alice@example.com"); !IsKVOperationFailed(err) {
+				t.Fatalf("GetUserByEmail = %v, want a KV failure", err)
+			}
+		})
+	}
+}
+
+func TestUserLookupMissingKey(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+
+	var appErr *AppError
+	_, err := client.GetUserByEmail(ctx, "nobody@example.com")
+	if !IsUserNotFound(err) || !errors.As(err, &appErr) || appErr.Code != http.StatusNotFound {
+		t.Fatalf("GetUserByEmail for an unknown email = %v, want user not found", err)
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+	sink := &recordingAuditSink{}
+	client.SetAuditSink(sink)
+
+	var appErr *AppError
+	_, err := client.Login(ctx, "nobody@example.com", "password")
+	if !IsInvalidCredentials(err) || IsUserNotFound(err) || !errors.As(err, &appErr) || appErr.Code != http.StatusUnauthorized {
+		t.Fatalf("Login for an unknown email = %v, want invalid credentials", err)
+	}
+
+	// The password is checked against a hash as costly as a real one
+	if cost, err := bcrypt.Cost(dummyPasswordHash()); err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("dummy hash cost = %d, %v; want %d", cost, err, bcrypt.DefaultCost)
+	}
+
+	// The audit trail still tells the cases apart
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) != 1 || sink.events[0].Reason != OutcomeUserNotFound {
+		t.Fatalf("audit events %+v, want a failed login for an unknown user", sink.events)
+	}
+}
@@ -0,0 +1,202 @@
+// Package main runs a ready-to-deploy authentication HTTP server backed by
+// the Cloudflare Auth SDK.
+//
+// Configuration is read from the environment (and a .env file, if present)
+// using the variables documented in .env.example.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+)
+
+const (
+	shutdownTimeout  = 15 * time.Second
+	readinessTimeout = 5 * time.Second
+)
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	if err := run(logger); err != nil {
+		logger.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger) error {
+	// A missing .env file is fine; the environment may already be set
+	_ = godotenv.Load()
+
+	opts, err := loadOptions()
+	if err != nil {
+		return err
+	}
+
+	client, err := sdk.NewClient(opts.WithLogger(logger))
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	authHandler := client.Handler(nil)
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", authHandler)
+	mux.Handle("GET "+sdk.OpenAPIPath, authHandler)
+
+	// Throttle credential endpoints per client IP
+	if value := os.Getenv("AUTH_RATE_LIMIT_PER_MINUTE"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid AUTH_RATE_LIMIT_PER_MINUTE: %w", err)
+		}
+		limiter, err := client.NewRateLimiter(&sdk.RateLimitOptions{
+			Name:      "auth",
+			Limit:     limit,
+			Window:    time.Minute,
+			Algorithm: sdk.SlidingWindow,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid AUTH_RATE_LIMIT_PER_MINUTE: %w", err)
+		}
+		limited := limiter.Middleware(sdk.RateLimitByIP)(authHandler)
+		mux.Handle("POST /auth/login", limited)
+		mux.Handle("POST /auth/register", limited)
+	}
+
+	mux.HandleFunc("GET /healthz", handleHealth)
+	mux.HandleFunc("GET /readyz", readinessHandler(client))
+
+	server := &http.Server{
+		Addr:              ":" + getEnv("SERVER_PORT", "8080"),
+		Handler:           logRequests(logger, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("server listening", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+
+	return <-errCh
+}
+
+// loadOptions builds client options from the environment
+func loadOptions() (*sdk.ClientOptions, error) {
+	opts := &sdk.ClientOptions{
+		APIToken:    os.Getenv("CLOUDFLARE_API_TOKEN"),
+		APIKey:      os.Getenv("CLOUDFLARE_API_KEY"),
+		Email:       os.Getenv("CLOUDFLARE_EMAIL"),
+		AccountID:   os.Getenv("CLOUDFLARE_ACCOUNT_ID"),
+		NamespaceID: os.Getenv("CLOUDFLARE_NAMESPACE_ID"),
+		JWTSecret:   os.Getenv("JWT_SECRET"),
+	}
+
+	if value := os.Getenv("JWT_EXPIRATION_HOURS"); value != "" {
+		hours, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_EXPIRATION_HOURS: %w", err)
+		}
+		opts.JWTExpirationHours = hours
+	}
+
+	if value := os.Getenv("SESSION_IDLE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_IDLE_TIMEOUT: %w", err)
+		}
+		opts.SessionIdleTimeout = timeout
+	}
+
+	return opts, nil
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	sdk.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessHandler reports ready once the KV namespace is reachable
+func readinessHandler(client *sdk.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		if _, err := client.KVList(ctx, "", 1); err != nil {
+			sdk.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+			return
+		}
+
+		sdk.WriteJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request with its status and duration
+func logRequests(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
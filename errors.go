@@ -17,15 +17,36 @@ var (
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid email or password")
 
+	// Service account errors
+	ErrServiceAccountNotFound = errors.New("service account not found")
+
 	// Token errors
 	ErrInvalidToken = errors.New("invalid or expired token")
 	ErrTokenExpired = errors.New("token has expired")
 
+	// Session errors
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session has expired")
+
 	// Input errors
 	ErrInvalidInput = errors.New("invalid input parameters")
 
+	// Rate limit errors
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// Webhook errors
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// API key errors
+	ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
 	// KV errors
 	ErrKVOperationFailed = errors.New("KV operation failed")
+	ErrKVUnavailable     = errors.New("KV is unavailable")
+	ErrKeyNotFound       = errors.New("key not found")
+	ErrDecodeFailed      = errors.New("failed to decode value")
+	ErrSchemaMismatch    = errors.New("schema version mismatch")
+	ErrMetadataTooLarge  = errors.New("metadata exceeds 1024 bytes")
 )
 
 // AppError represents an application error with additional context.
@@ -86,3 +107,40 @@ func IsInvalidCredentials(err error) bool {
 func IsInvalidToken(err error) bool {
 	return errors.Is(err, ErrInvalidToken)
 }
+
+// IsSessionExpired checks if the error is a "session expired" or "session not found" error.
+func IsSessionExpired(err error) bool {
+	return errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrSessionNotFound)
+}
+
+// IsRateLimited checks if the error is a "rate limit exceeded" error.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsInvalidAPIKey checks if the error is an "invalid API key" error.
+func IsInvalidAPIKey(err error) bool {
+	return errors.Is(err, ErrInvalidAPIKey)
+}
+
+// IsServiceAccountNotFound checks if the error is a service account not found error.
+func IsServiceAccountNotFound(err error) bool {
+	return errors.Is(err, ErrServiceAccountNotFound)
+}
+
+// IsKVOperationFailed checks if the error is a KV storage failure other than
+// a missing key.
+func IsKVOperationFailed(err error) bool {
+	return errors.Is(err, ErrKVOperationFailed)
+}
+
+// IsKVUnavailable checks if the error was returned without calling KV because
+// the circuit breaker is open.
+func IsKVUnavailable(err error) bool {
+	return errors.Is(err, ErrKVUnavailable)
+}
+
+// IsKeyNotFound checks if the error is a "key not found" error.
+func IsKeyNotFound(err error) bool {
+	return errors.Is(err, ErrKeyNotFound)
+}
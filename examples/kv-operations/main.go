@@ -37,7 +37,7 @@ func main() {
 	fmt.Println("\n=== Storing Data with Expiration ===")
 	err = client.KVSet(ctx, "session:12345", []byte("session-data"), &sdk.KVWriteOptions{
 		ExpirationTTL: 3600, // 1 hour
-		Metadata:      "temporary session data",
+		Metadata:      map[string]string{"kind": "session"},
 	})
 	if err != nil {
 		log.Fatalf("Failed to set value with expiration: %v", err)
@@ -0,0 +1,367 @@
+package cloudflare_auth_sdk
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go/v6"
+	"github.com/cloudflare/cloudflare-go/v6/option"
+)
+
+const (
+	testAccountID   = "test-account"
+	testNamespaceID = "test-namespace"
+)
+
+// fakeKVEntry is a value stored by fakeKV
+type fakeKVEntry struct {
+	value      []byte
+	metadata   json.RawMessage
+	expiration time.Time
+}
+
+// fakeKV is an in-memory implementation of the Workers KV REST endpoints
+// used by the client
+type fakeKV struct {
+	mu      sync.Mutex
+	entries map[string]fakeKVEntry
+
+	// fail, if set, answers a request with the returned status instead of
+	// handling it when the status is non-zero
+	fail func(r *http.Request) int
+
+	// requests counts the requests by method and endpoint, e.g. "GET values"
+	requests map[string]int
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{
+		entries:  make(map[string]fakeKVEntry),
+		requests: make(map[string]int),
+	}
+}
+
+// newTestClient returns a client backed by a fresh fakeKV. opts may be nil;
+// the connection settings are filled in.
+func newTestClient(t *testing.T, opts *ClientOptions) (*Client, *fakeKV) {
+	t.Helper()
+
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	opts.APIToken = "test-token"
+	opts.AccountID = testAccountID
+	opts.NamespaceID = testNamespaceID
+	if opts.JWTSecret == "" {
+		opts.JWTSecret = "test-secret-with-at-least-32-bytes"
+	}
+
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	kv := newFakeKV()
+	serveKV(t, client, kv)
+	return client, kv
+}
+
+// serveKV points the client's Cloudflare API calls at handler
+func serveKV(t *testing.T, client *Client, handler http.Handler) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client.cfClient = cloudflare.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIToken("test-token"),
+		option.WithMaxRetries(0),
+	)
+}
+
+// get returns the live value of key
+func (f *fakeKV) get(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.live(key)
+	return entry.value, ok
+}
+
+// put stores a value directly
+func (f *fakeKV) put(key string, value []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries[key] = fakeKVEntry{value: value}
+}
+
+// keys returns the live keys with prefix in order
+func (f *fakeKV) keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []string
+	for key := range f.entries {
+		if _, ok := f.live(key); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// count returns the number of requests made to an endpoint
+func (f *fakeKV) count(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[endpoint]
+}
+
+// live returns an entry unless it is missing or expired; f.mu must be held
+func (f *fakeKV) live(key string) (fakeKVEntry, bool) {
+	entry, ok := f.entries[key]
+	if !ok || (!entry.expiration.IsZero() && !time.Now().Before(entry.expiration)) {
+		return fakeKVEntry{}, false
+	}
+	return entry, true
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/accounts/" + testAccountID + "/storage/kv/namespaces/" + testNamespaceID + "/"
+	rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok {
+		writeFakeKVError(w, http.StatusNotFound, "unknown path")
+		return
+	}
+	endpoint, key, _ := strings.Cut(rest, "/")
+
+	f.mu.Lock()
+	f.requests[r.Method+" "+endpoint]++
+	fail := f.fail
+	f.mu.Unlock()
+
+	if fail != nil {
+		if status := fail(r); status != 0 {
+			writeFakeKVError(w, status, "injected failure")
+			return
+		}
+	}
+
+	switch {
+	case endpoint == "values" && r.Method == http.MethodGet:
+		f.serveGet(w, key)
+	case endpoint == "values" && r.Method == http.MethodPut:
+		f.servePut(w, r, key)
+	case endpoint == "values" && r.Method == http.MethodDelete:
+		f.mu.Lock()
+		delete(f.entries, key)
+		f.mu.Unlock()
+		writeFakeKVResult(w, struct{}{})
+	case endpoint == "metadata" && r.Method == http.MethodGet:
+		f.serveMetadata(w, key)
+	case endpoint == "keys" && r.Method == http.MethodGet:
+		f.serveList(w, r)
+	case endpoint == "bulk" && key == "" && r.Method == http.MethodPut:
+		f.serveBulkPut(w, r)
+	case endpoint == "bulk" && key == "delete" && r.Method == http.MethodPost:
+		f.serveBulkDelete(w, r)
+	default:
+		writeFakeKVError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+func (f *fakeKV) serveGet(w http.ResponseWriter, key string) {
+	f.mu.Lock()
+	entry, ok := f.live(key)
+	f.mu.Unlock()
+
+	if !ok {
+		writeFakeKVNotFound(w)
+		return
+	}
+	if !entry.expiration.IsZero() {
+		w.Header().Set("Expiration", strconv.FormatInt(entry.expiration.Unix(), 10))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(entry.value)
+}
+
+func (f *fakeKV) servePut(w http.ResponseWriter, r *http.Request, key string) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeFakeKVError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry := fakeKVEntry{value: []byte(r.FormValue("value"))}
+	if metadata := r.FormValue("metadata"); metadata != "" {
+		entry.metadata = json.RawMessage(metadata)
+	}
+	entry.expiration = fakeKVExpiration(r.URL.Query().Get("expiration"), r.URL.Query().Get("expiration_ttl"))
+
+	f.mu.Lock()
+	f.entries[key] = entry
+	f.mu.Unlock()
+	writeFakeKVResult(w, struct{}{})
+}
+
+func (f *fakeKV) serveMetadata(w http.ResponseWriter, key string) {
+	f.mu.Lock()
+	entry, ok := f.live(key)
+	f.mu.Unlock()
+
+	if !ok {
+		writeFakeKVNotFound(w)
+		return
+	}
+	var metadata interface{}
+	if entry.metadata != nil {
+		metadata = entry.metadata
+	}
+	writeFakeKVResult(w, metadata)
+}
+
+func (f *fakeKV) serveList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 1000
+	if l, err := strconv.ParseFloat(query.Get("limit"), 64); err == nil && l > 0 {
+		limit = int(l)
+	}
+	start := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		start, _ = strconv.Atoi(cursor)
+	}
+
+	keys := f.keys(query.Get("prefix"))
+	end := start + limit
+	next := strconv.Itoa(end)
+	if end >= len(keys) {
+		end, next = len(keys), ""
+	}
+
+	type item struct {
+		Name       string          `json:"name"`
+		Expiration float64         `json:"expiration,omitempty"`
+		Metadata   json.RawMessage `json:"metadata,omitempty"`
+	}
+	items := []item{}
+	f.mu.Lock()
+	for _, key := range keys[min(start, end):end] {
+		entry := f.entries[key]
+		it := item{Name: key, Metadata: entry.metadata}
+		if !entry.expiration.IsZero() {
+			it.Expiration = float64(entry.expiration.Unix())
+		}
+		items = append(items, it)
+	}
+	f.mu.Unlock()
+
+	writeFakeKVJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"errors":      []interface{}{},
+		"messages":    []interface{}{},
+		"result":      items,
+		"result_info": map[string]interface{}{"count": len(items), "cursor": next},
+	})
+}
+
+func (f *fakeKV) serveBulkPut(w http.ResponseWriter, r *http.Request) {
+	var items []struct {
+		Key           string          `json:"key"`
+		Value         string          `json:"value"`
+		Expiration    float64         `json:"expiration"`
+		ExpirationTTL float64         `json:"expiration_ttl"`
+		Metadata      json.RawMessage `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		writeFakeKVError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	for _, item := range items {
+		entry := fakeKVEntry{value: []byte(item.Value), metadata: item.Metadata}
+		entry.expiration = fakeKVExpiration(
+			strconv.FormatFloat(item.Expiration, 'f', 0, 64),
+			strconv.FormatFloat(item.ExpirationTTL, 'f', 0, 64),
+		)
+		f.entries[item.Key] = entry
+	}
+	f.mu.Unlock()
+
+	writeFakeKVResult(w, map[string]interface{}{
+		"successful_key_count": len(items),
+		"unsuccessful_keys":    []string{},
+	})
+}
+
+func (f *fakeKV) serveBulkDelete(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		writeFakeKVError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	for _, key := range keys {
+		delete(f.entries, key)
+	}
+	f.mu.Unlock()
+
+	writeFakeKVResult(w, map[string]interface{}{"successful_key_count": len(keys)})
+}
+
+// fakeKVExpiration converts the expiration parameters of a write
+func fakeKVExpiration(expiration, ttl string) time.Time {
+	if seconds, err := strconv.ParseInt(ttl, 10, 64); err == nil && seconds > 0 {
+		return time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	if unix, err := strconv.ParseInt(expiration, 10, 64); err == nil && unix > 0 {
+		return time.Unix(unix, 0)
+	}
+	return time.Time{}
+}
+
+func writeFakeKVResult(w http.ResponseWriter, result interface{}) {
+	writeFakeKVJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"errors":   []interface{}{},
+		"messages": []interface{}{},
+		"result":   result,
+	})
+}
+
+func writeFakeKVError(w http.ResponseWriter, status int, message string) {
+	writeFakeKVErrorCode(w, status, 10000+status, message)
+}
+
+// writeFakeKVNotFound answers like Cloudflare does for a missing key
+func writeFakeKVNotFound(w http.ResponseWriter) {
+	writeFakeKVErrorCode(w, http.StatusNotFound, kvKeyNotFoundCode, "get: 'key not found'")
+}
+
+func writeFakeKVErrorCode(w http.ResponseWriter, status, code int, message string) {
+	writeFakeKVJSON(w, status, map[string]interface{}{
+		"success":  false,
+		"errors":   []interface{}{map[string]interface{}{"code": code, "message": message}},
+		"messages": []interface{}{},
+		"result":   nil,
+	})
+}
+
+func writeFakeKVJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
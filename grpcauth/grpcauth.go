@@ -0,0 +1,208 @@
+// Package grpcauth provides gRPC server interceptors that authenticate calls
+// with the Cloudflare Auth SDK.
+//
+// The bearer token is read from the "authorization" metadata key, checked
+// with Client.Authenticate, like the HTTP middleware does, and stored in the
+// context so handlers can use cloudflare_auth_sdk.UserFromContext,
+// ServiceAccountFromContext, ClaimsFromContext and APIKeyFromContext.
+//
+// Basic usage:
+//
+//	server := grpc.NewServer(
+//	    grpc.UnaryInterceptor(grpcauth.UnaryServerInterceptor(client, nil)),
+//	    grpc.StreamInterceptor(grpcauth.StreamServerInterceptor(client, nil)),
+//	)
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Options contains options for the interceptors.
+type Options struct {
+	MetadataKey string // Metadata key holding the token (default: "authorization")
+
+	// Optional lets calls without a token through unauthenticated.
+	// Calls carrying an invalid token are still rejected.
+	Optional bool
+
+	// ClaimsOnly verifies tokens with Client.VerifySession instead of
+	// Client.ValidateSession, avoiding KV reads. Only the claims are
+	// stored in the context.
+	ClaimsOnly bool
+
+	// FallbackToClaims verifies tokens with Client.VerifySession while the
+	// KV circuit breaker is open, instead of failing calls with Unavailable.
+	FallbackToClaims bool
+
+	// APIKeys accepts API keys ("Bearer ak_...") as an alternative to
+	// tokens. The key and its owner are stored in the context.
+	APIKeys bool
+
+	// SkipMethods lists full method names (e.g. "/pkg.Service/Method")
+	// that are not authenticated.
+	SkipMethods []string
+}
+
+// UnaryServerInterceptor returns a unary interceptor that authenticates calls.
+func UnaryServerInterceptor(client *sdk.Client, opts *Options) grpc.UnaryServerInterceptor {
+	a := newAuthenticator(client, opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a stream interceptor that authenticates calls.
+func StreamServerInterceptor(client *sdk.Client, opts *Options) grpc.StreamServerInterceptor {
+	a := newAuthenticator(client, opts)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// StatusFromError converts an SDK error into a gRPC status error, mapping
+// AppError.Code to the closest gRPC status code.
+func StatusFromError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *sdk.AppError
+	if !errors.As(err, &appErr) {
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	message := appErr.Message
+	if message == "" {
+		message = http.StatusText(appErr.Code)
+	}
+
+	return status.Error(codeFromHTTP(appErr.Code), message)
+}
+
+// authenticator holds the normalized interceptor options
+type authenticator struct {
+	client      *sdk.Client
+	metadataKey string
+	optional    bool
+	authOpts    *sdk.AuthenticateOptions
+	skip        map[string]bool
+}
+
+func newAuthenticator(client *sdk.Client, opts *Options) *authenticator {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	metadataKey := strings.ToLower(opts.MetadataKey)
+	if metadataKey == "" {
+		metadataKey = "authorization"
+	}
+
+	skip := make(map[string]bool, len(opts.SkipMethods))
+	for _, method := range opts.SkipMethods {
+		skip[method] = true
+	}
+
+	return &authenticator{
+		client:      client,
+		metadataKey: metadataKey,
+		optional:    opts.Optional,
+		authOpts: &sdk.AuthenticateOptions{
+			ClaimsOnly:       opts.ClaimsOnly,
+			FallbackToClaims: opts.FallbackToClaims,
+			APIKeys:          opts.APIKeys,
+		},
+		skip: skip,
+	}
+}
+
+// authenticate validates the call's token and returns the enriched context
+func (a *authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if a.skip[fullMethod] {
+		return ctx, nil
+	}
+
+	token := a.tokenFromMetadata(ctx)
+	if token == "" {
+		if a.optional {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing authentication token")
+	}
+
+	ctx, err := a.client.Authenticate(ctx, token, a.authOpts)
+	if err != nil {
+		return nil, StatusFromError(err)
+	}
+	return ctx, nil
+}
+
+// tokenFromMetadata reads a bearer token from the incoming metadata
+func (a *authenticator) tokenFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	for _, value := range md.Get(a.metadataKey) {
+		const prefix = "bearer "
+		if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+			return strings.TrimSpace(value[len(prefix):])
+		}
+	}
+	return ""
+}
+
+// codeFromHTTP maps an HTTP status code to a gRPC status code
+func codeFromHTTP(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// serverStream overrides the context of a wrapped grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
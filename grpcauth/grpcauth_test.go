@@ -0,0 +1,427 @@
+package grpcauth
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const healthCheck = "/grpc.health.v1.Health/Check"
+
+func TestCodeFromHTTP(t *testing.T) {
+	tests := []struct {
+		status int
+		want   codes.Code
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.AlreadyExists},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{http.StatusInternalServerError, codes.Internal},
+		{http.StatusTeapot, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		if got := codeFromHTTP(tt.status); got != tt.want {
+			t.Errorf("codeFromHTTP(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStatusFromError(t *testing.T) {
+	err := StatusFromError(sdk.NewAppError("op", sdk.ErrUserNotFound, "user not found", 404))
+	if st := status.Convert(err); st.Code() != codes.NotFound || st.Message() != "user not found" {
+		t.Errorf("StatusFromError(AppError) = %v", err)
+	}
+
+	// Other errors do not leak their message
+	err = StatusFromError(context.DeadlineExceeded)
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Errorf("StatusFromError(other) = %v", err)
+	}
+
+	if StatusFromError(nil) != nil {
+		t.Error("StatusFromError(nil) != nil")
+	}
+}
+
+func TestTokenFromMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		md     metadata.MD
+		wanted string
+	}{
+		{"bearer", "", metadata.Pairs("authorization", "Bearer abc"), "abc"},
+		{"case insensitive scheme", "", metadata.Pairs("authorization", "bEaReR abc"), "abc"},
+		{"surrounding spaces", "", metadata.Pairs("authorization", "Bearer  abc "), "abc"},
+		{"other scheme", "", metadata.Pairs("authorization", "Basic abc"), ""},
+		{"scheme only", "", metadata.Pairs("authorization", "Bearer "), ""},
+		{"no prefix", "", metadata.Pairs("authorization", "abc"), ""},
+		{"first bearer value", "", metadata.Pairs("authorization", "Basic x", "authorization", "Bearer abc"), "abc"},
+		{"custom key", "X-Auth-Token", metadata.Pairs("x-auth-token", "Bearer abc"), "abc"},
+		{"custom key ignores authorization", "X-Auth-Token", metadata.Pairs("authorization", "Bearer abc"), ""},
+		{"no metadata", "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(nil, &Options{MetadataKey: tt.key})
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			if got := a.tokenFromMetadata(ctx); got != tt.wanted {
+				t.Errorf("tokenFromMetadata = %q, want %q", got, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	ctx := context.Background()
+	kv := newFakeKV(t)
+	client, err := sdk.NewClient(&sdk.ClientOptions{
+		APIToken:       "test-token",
+		AccountID:      "test-account",
+		NamespaceID:    "test-namespace",
+		JWTSecret:      "test-secret-with-at-least-32-bytes",
+		CircuitBreaker: &sdk.CircuitBreakerOptions{FailureThreshold: 1, CoolDown: time.Hour},
+		Retry:          &sdk.RetryPolicy{MaxAttempts: 1}, // Fail KV requests without retrying
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey, err := client.CreateAPIKey(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		api, _ := startServer(t, client, nil)
+		_, err := api.Check(ctx, &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Check without a token = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		api, seen := startServer(t, client, nil)
+		if _, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := sdk.UserFromContext(seen.get())
+		if !ok || got.ID != user.ID {
+			t.Fatalf("user in context = %+v, %v", got, ok)
+		}
+		if claims, ok := sdk.ClaimsFromContext(seen.get()); !ok || claims.UserID != user.ID {
+			t.Fatalf("claims in context = %+v, %v", claims, ok)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		api, _ := startServer(t, client, nil)
+		_, err := api.Check(withToken("not-a-token"), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Check with an invalid token = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		api, seen := startServer(t, client, &Options{Optional: true})
+		if _, err := api.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check without a token: %v", err)
+		}
+		if _, ok := sdk.UserFromContext(seen.get()); ok {
+			t.Fatal("user in context for an unauthenticated call")
+		}
+		_, err := api.Check(withToken("not-a-token"), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("optional Check with an invalid token = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("skip methods", func(t *testing.T) {
+		api, _ := startServer(t, client, &Options{SkipMethods: []string{healthCheck}})
+		if _, err := api.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("skipped Check without a token: %v", err)
+		}
+	})
+
+	t.Run("metadata key", func(t *testing.T) {
+		api, _ := startServer(t, client, &Options{MetadataKey: "X-Auth-Token"})
+		md := metadata.AppendToOutgoingContext(ctx, "x-auth-token", "Bearer "+login.Token)
+		if _, err := api.Check(md, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check with the custom metadata key: %v", err)
+		}
+		_, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Check with the default metadata key = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("claims only", func(t *testing.T) {
+		api, seen := startServer(t, client, &Options{ClaimsOnly: true})
+		requests := kv.count()
+		if _, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if kv.count() != requests {
+			t.Fatal("claims-only authentication read KV")
+		}
+		if _, ok := sdk.UserFromContext(seen.get()); ok {
+			t.Fatal("user in context for claims-only authentication")
+		}
+		if claims, ok := sdk.ClaimsFromContext(seen.get()); !ok || claims.UserID != user.ID {
+			t.Fatalf("claims in context = %+v, %v", claims, ok)
+		}
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		api, seen := startServer(t, client, &Options{APIKeys: true})
+		if _, err := api.Check(withToken(apiKey.Key), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if key, ok := sdk.APIKeyFromContext(seen.get()); !ok || key.ID != apiKey.ID {
+			t.Fatalf("API key in context = %+v, %v", key, ok)
+		}
+		if got, ok := sdk.UserFromContext(seen.get()); !ok || got.ID != user.ID {
+			t.Fatalf("user in context = %+v, %v", got, ok)
+		}
+
+		api, _ = startServer(t, client, nil)
+		_, err := api.Check(withToken(apiKey.Key), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Check with an API key while disabled = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		api, seen := startServer(t, client, nil)
+		streamCtx, cancel := context.WithCancel(withToken(login.Token))
+		defer cancel()
+		stream, err := api.Watch(streamCtx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := sdk.UserFromContext(seen.get()); !ok || got.ID != user.ID {
+			t.Fatalf("user in stream context = %+v, %v", got, ok)
+		}
+
+		stream, err = api.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Watch without a token = %v, want Unauthenticated", err)
+		}
+	})
+
+	// Runs last, since it leaves the circuit breaker open
+	t.Run("fallback to claims", func(t *testing.T) {
+		kv.setFailing(true)
+		defer kv.setFailing(false)
+
+		api, _ := startServer(t, client, nil)
+		if _, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Check with KV failing = %v, want Unavailable", err)
+		}
+		if _, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Check with the circuit open = %v, want Unavailable", err)
+		}
+
+		api, seen := startServer(t, client, &Options{FallbackToClaims: true})
+		if _, err := api.Check(withToken(login.Token), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check with fallback: %v", err)
+		}
+		if claims, ok := sdk.ClaimsFromContext(seen.get()); !ok || claims.UserID != user.ID {
+			t.Fatalf("claims in context = %+v, %v", claims, ok)
+		}
+		if _, err := api.Check(withToken("not-a-token"), &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("fallback Check with an invalid token = %v, want Unauthenticated", err)
+		}
+	})
+}
+
+// seenContext records the context the last call reached the service with
+type seenContext struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+func (s *seenContext) set(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+}
+
+func (s *seenContext) get() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+// startServer serves the health service over bufconn behind the
+// interceptors, and records the context each call reaches the service with
+func startServer(t *testing.T, client *sdk.Client, opts *Options) (healthpb.HealthClient, *seenContext) {
+	t.Helper()
+
+	seen := &seenContext{ctx: context.Background()}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			UnaryServerInterceptor(client, opts),
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				seen.set(ctx)
+				return handler(ctx, req)
+			},
+		),
+		grpc.ChainStreamInterceptor(
+			StreamServerInterceptor(client, opts),
+			func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				seen.set(stream.Context())
+				return handler(srv, stream)
+			},
+		),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn), seen
+}
+
+// fakeKV stores Workers KV values in memory, serving the single-value
+// endpoints the authentication flows use
+type fakeKV struct {
+	mu       sync.Mutex
+	values   map[string]string
+	requests int
+	failing  bool
+}
+
+// newFakeKV starts a fakeKV and points new Cloudflare clients at it
+func newFakeKV(t *testing.T) *fakeKV {
+	t.Helper()
+
+	kv := &fakeKV{values: make(map[string]string)}
+	server := httptest.NewServer(kv)
+	t.Cleanup(server.Close)
+	t.Setenv("CLOUDFLARE_BASE_URL", server.URL)
+	return kv
+}
+
+func (f *fakeKV) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeKV) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, key, ok := strings.Cut(r.URL.Path, "/values/")
+	if err := r.ParseMultipartForm(1 << 20); err != nil && r.Method == http.MethodPut {
+		ok = false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	switch {
+	case f.failing:
+		writeKVResponse(w, http.StatusServiceUnavailable, nil)
+	case !ok:
+		writeKVResponse(w, http.StatusBadRequest, nil)
+	case r.Method == http.MethodGet:
+		value, found := f.values[key]
+		if !found {
+			writeKVResponse(w, http.StatusNotFound, nil)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte(value))
+	case r.Method == http.MethodPut:
+		f.values[key] = r.FormValue("value")
+		writeKVResponse(w, http.StatusOK, struct{}{})
+	case r.Method == http.MethodDelete:
+		delete(f.values, key)
+		writeKVResponse(w, http.StatusOK, struct{}{})
+	default:
+		writeKVResponse(w, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// writeKVResponse writes a Cloudflare API envelope
+func writeKVResponse(w http.ResponseWriter, status int, result interface{}) {
+	errs := []interface{}{}
+	if status != http.StatusOK {
+		code := 10000 + status
+		if status == http.StatusNotFound {
+			code = 10009 // Key not found
+		}
+		errs = append(errs, map[string]interface{}{"code": code, "message": http.StatusText(status)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  status == http.StatusOK,
+		"errors":   errs,
+		"messages": []interface{}{},
+		"result":   result,
+	})
+}
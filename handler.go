@@ -0,0 +1,185 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// maxRequestBodySize limits the size of JSON request bodies.
+const maxRequestBodySize = 1 << 20
+
+// CredentialsRequest is the JSON body for register and login requests.
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Handler returns an http.Handler serving the JSON REST authentication API:
+//
+//	POST   /auth/register  register a user, returns UserInfo
+//	POST   /auth/login     log in, returns LoginResponse
+//	POST   /auth/refresh   exchange a valid token for a new one
+//	GET    /auth/me        return the authenticated user
+//	POST   /auth/logout    end the token's session
+//	DELETE /auth/me        delete the authenticated user
+//	GET    /openapi.json   the OpenAPI document describing these endpoints
+//
+// Tokens are read according to opts, which may be nil. Errors are written
+// as a JSON ErrorResponse; tokens of deleted users are rejected with 401.
+func (c *Client) Handler(opts *MiddlewareOptions) http.Handler {
+	if opts == nil {
+		opts = &MiddlewareOptions{}
+	}
+	// The authenticated endpoints always require a token and a user
+	authOpts := *opts
+	authOpts.Optional = false
+	authOpts.ClaimsOnly = false
+	authOpts.FallbackToClaims = false
+	authOpts.APIKeys = false
+	auth := c.middleware(&authOpts, func(w http.ResponseWriter, err error) {
+		writeAuthError(w, "Handler.authenticate", err)
+	})
+
+	h := &authHandler{client: c, opts: &authOpts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/register", h.register)
+	mux.HandleFunc("POST /auth/login", h.login)
+	mux.HandleFunc("POST /auth/refresh", h.refresh)
+	mux.HandleFunc("POST /auth/logout", h.logout)
+	mux.Handle("GET /auth/me", auth(http.HandlerFunc(h.me)))
+	mux.Handle("DELETE /auth/me", auth(http.HandlerFunc(h.deleteMe)))
+	mux.HandleFunc("GET "+OpenAPIPath, serveOpenAPI)
+
+	return mux
+}
+
+// authHandler implements the endpoints served by Client.Handler
+type authHandler struct {
+	client *Client
+	opts   *MiddlewareOptions
+}
+
+// clientIPContext returns the request context carrying the client IP
+func (h *authHandler) clientIPContext(r *http.Request) context.Context {
+	clientIP := h.opts.ClientIP
+	if clientIP == nil {
+		clientIP = RateLimitByIP
+	}
+	return NewClientIPContext(r.Context(), clientIP(r))
+}
+
+func (h *authHandler) register(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeCredentials(w, r, "Handler.register")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	user, err := h.client.Register(h.clientIPContext(r), req.Email, req.Password)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, user.ToUserInfo())
+}
+
+func (h *authHandler) login(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeCredentials(w, r, "Handler.login")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	resp, err := h.client.Login(h.clientIPContext(r), req.Email, req.Password)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *authHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	token := h.opts.extractToken(r)
+	if token == "" {
+		WriteError(w, NewAppError("Handler.refresh", ErrInvalidToken, "missing authentication token", 401))
+		return
+	}
+
+	resp, err := h.client.RefreshToken(r.Context(), token)
+	if err != nil {
+		writeAuthError(w, "Handler.refresh", err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *authHandler) logout(w http.ResponseWriter, r *http.Request) {
+	token := h.opts.extractToken(r)
+	if token == "" {
+		WriteError(w, NewAppError("Handler.logout", ErrInvalidToken, "missing authentication token", 401))
+		return
+	}
+
+	if err := h.client.Logout(r.Context(), token); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *authHandler) me(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r, "Handler.me")
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, user.ToUserInfo())
+}
+
+func (h *authHandler) deleteMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r, "Handler.deleteMe")
+	if !ok {
+		return
+	}
+	if err := h.client.DeleteUser(r.Context(), user.Email); err != nil {
+		writeAuthError(w, "Handler.deleteMe", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireUser returns the authenticated user, writing an error for service
+// accounts, which have no user profile
+func requireUser(w http.ResponseWriter, r *http.Request, op string) (*User, bool) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteError(w, NewAppError(op, ErrInvalidToken, "service accounts have no user profile", 403))
+	}
+	return user, ok
+}
+
+// writeAuthError writes err for an authenticated endpoint. Tokens of
+// deleted users are rejected with 401 like any other invalid token.
+func writeAuthError(w http.ResponseWriter, op string, err error) {
+	if IsUserNotFound(err) {
+		err = NewAppError(op, ErrInvalidToken, "invalid authentication token", 401)
+	}
+	WriteError(w, err)
+}
+
+// decodeCredentials parses a CredentialsRequest from the request body
+func decodeCredentials(w http.ResponseWriter, r *http.Request, op string) (*CredentialsRequest, error) {
+	var req CredentialsRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, NewAppError(op, ErrInvalidInput, "invalid JSON request body", 400)
+	}
+	return &req, nil
+}
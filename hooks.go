@@ -0,0 +1,112 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+)
+
+// BeforeRegisterHook runs before a user is registered. Returning an error
+// rejects the registration.
+type BeforeRegisterHook func(ctx context.Context, email string) error
+
+// AfterRegisterHook runs after a user has been registered.
+type AfterRegisterHook func(ctx context.Context, user *User)
+
+// BeforeLoginHook runs after the password has been verified and before the
+// token is issued. Returning an error rejects the login.
+//
+// Hooks may add custom claims to claims.Extra, which is never nil; the
+// other fields are set by the SDK and changes to them are ignored.
+type BeforeLoginHook func(ctx context.Context, user *User, claims *Claims) error
+
+// AfterLoginHook runs after a token has been issued.
+type AfterLoginHook func(ctx context.Context, user *User, resp *LoginResponse)
+
+// BeforeDeleteUserHook runs before a user is deleted. Returning an error
+// keeps the user.
+type BeforeDeleteUserHook func(ctx context.Context, user *User) error
+
+// AfterDeleteUserHook runs after a user has been deleted.
+type AfterDeleteUserHook func(ctx context.Context, user *User)
+
+// TokenValidatedHook runs after a token has been accepted: by ValidateToken
+// or ValidateSession, with its user or service account loaded, or by
+// VerifySession, which the ClaimsOnly and FallbackToClaims modes of the
+// middleware and the grpcauth interceptors use, with only the claims set.
+//
+// info.User is nil for service account tokens and for VerifySession, so
+// hooks must check it before use. Returning an error rejects the token.
+type TokenValidatedHook func(ctx context.Context, info *SessionInfo) error
+
+// hooks holds the registered lifecycle hooks, run in registration order.
+type hooks struct {
+	beforeRegister   []BeforeRegisterHook
+	afterRegister    []AfterRegisterHook
+	beforeLogin      []BeforeLoginHook
+	afterLogin       []AfterLoginHook
+	beforeDeleteUser []BeforeDeleteUserHook
+	afterDeleteUser  []AfterDeleteUserHook
+	tokenValidated   []TokenValidatedHook
+}
+
+// BeforeRegister adds a hook that runs before Register.
+//
+// Hooks of every kind run synchronously inside the operation, in the order
+// they were added, and must be added before the client is used. A hook
+// rejects an operation by returning an error, which stops the remaining
+// hooks: an AppError is returned to the caller unchanged, so the hook
+// chooses the message and status code; other errors are wrapped in an
+// AppError with code 403.
+//
+// Example:
+//
+//	client.BeforeRegister(func(ctx context.Context, email string) error {
+//	    if strings.HasSuffix(email, "@blocked.example") {
+//	        return sdk.NewAppError("BeforeRegister", sdk.ErrInvalidInput, "email domain not allowed", 400)
+//	    }
+//	    return nil
+//	})
+func (c *Client) BeforeRegister(hook BeforeRegisterHook) {
+	c.hooks.beforeRegister = append(c.hooks.beforeRegister, hook)
+}
+
+// AfterRegister adds a hook that runs after a successful Register.
+func (c *Client) AfterRegister(hook AfterRegisterHook) {
+	c.hooks.afterRegister = append(c.hooks.afterRegister, hook)
+}
+
+// BeforeLogin adds a hook that runs during Login once the password has
+// been verified.
+func (c *Client) BeforeLogin(hook BeforeLoginHook) {
+	c.hooks.beforeLogin = append(c.hooks.beforeLogin, hook)
+}
+
+// AfterLogin adds a hook that runs after a successful Login.
+func (c *Client) AfterLogin(hook AfterLoginHook) {
+	c.hooks.afterLogin = append(c.hooks.afterLogin, hook)
+}
+
+// BeforeDeleteUser adds a hook that runs before DeleteUser removes a user.
+func (c *Client) BeforeDeleteUser(hook BeforeDeleteUserHook) {
+	c.hooks.beforeDeleteUser = append(c.hooks.beforeDeleteUser, hook)
+}
+
+// AfterDeleteUser adds a hook that runs after a successful DeleteUser.
+func (c *Client) AfterDeleteUser(hook AfterDeleteUserHook) {
+	c.hooks.afterDeleteUser = append(c.hooks.afterDeleteUser, hook)
+}
+
+// OnTokenValidated adds a hook that runs after a token has been validated
+// or, without KV access, verified. VerifyToken does not run it.
+func (c *Client) OnTokenValidated(hook TokenValidatedHook) {
+	c.hooks.tokenValidated = append(c.hooks.tokenValidated, hook)
+}
+
+// hookError converts the error of a rejecting hook into an AppError
+func hookError(op string, err error) error {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return NewAppError(op, err, "request rejected", 403)
+}
@@ -0,0 +1,237 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOnTokenValidatedRunsForClaimsOnlyVerification(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{
+		CircuitBreaker: &CircuitBreakerOptions{FailureThreshold: 1, CoolDown: time.Hour},
+	})
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []*SessionInfo
+	reject := false
+	client.OnTokenValidated(func(ctx context.Context, info *SessionInfo) error {
+		calls = append(calls, info)
+		if reject {
+			return errors.New("token revoked")
+		}
+		return nil
+	})
+
+	serve := func(opts *MiddlewareOptions) int {
+		handler := client.Middleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.Token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if status := serve(&MiddlewareOptions{ClaimsOnly: true}); status != http.StatusOK {
+		t.Fatalf("claims-only status %d", status)
+	}
+	if len(calls) != 1 || calls[0].User != nil || calls[0].Claims.UserID != resp.User.ID || calls[0].Remaining <= 0 {
+		t.Fatalf("hook calls %+v, want one with claims only", calls)
+	}
+
+	reject = true
+	if status := serve(&MiddlewareOptions{ClaimsOnly: true}); status != http.StatusForbidden {
+		t.Fatalf("claims-only status with a rejecting hook %d, want 403", status)
+	}
+
+	// With KV down, the fallback to claims runs the hook too
+	kv.fail = func(*http.Request) int { return http.StatusServiceUnavailable }
+	if status := serve(&MiddlewareOptions{}); status != http.StatusServiceUnavailable {
+		t.Fatalf("status with KV failing %d, want 503", status)
+	}
+	if status := serve(&MiddlewareOptions{FallbackToClaims: true}); status != http.StatusForbidden {
+		t.Fatalf("fallback status with a rejecting hook %d, want 403", status)
+	}
+
+	// VerifyToken does not run hooks
+	n := len(calls)
+	if _, err := client.VerifyToken(resp.Token); err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if len(calls) != n {
+		t.Fatal("VerifyToken ran the hooks")
+	}
+}
+
+func TestBeforeRegisterRejects(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+
+	var emails []string
+	client.BeforeRegister(func(ctx context.Context, email string) error {
+		emails = append(emails, email)
+		if email == "mallory@example.com" {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+	client.BeforeRegister(func(ctx context.Context, email string) error {
+		if email == "eve@example.com" {
+			return NewAppError("BeforeRegister", ErrInvalidInput, "email domain not allowed", 400)
+		}
+		return nil
+	})
+	registered := 0
+	client.AfterRegister(func(ctx context.Context, user *User) { registered++ })
+
+	var appErr *AppError
+	_, err := client.Register(ctx, "mallory@example.com", "password")
+	if !errors.As(err, &appErr) || appErr.Code != http.StatusForbidden {
+		t.Fatalf("register with a rejecting hook = %v, want 403", err)
+	}
+	// AppErrors are returned unchanged
+	_, err = client.Register(ctx, "eve@example.com", "password")
+	if !errors.As(err, &appErr) || appErr.Code != http.StatusBadRequest || appErr.Message != "email domain not allowed" {
+		t.Fatalf("register with a hook returning an AppError = %v", err)
+	}
+	if keys := kv.keys("user:"); len(keys) != 0 || registered != 0 {
+		t.Fatalf("rejected registrations stored %v and ran %d AfterRegister hooks", keys, registered)
+	}
+
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if registered != 1 || len(emails) != 3 {
+		t.Fatalf("%d AfterRegister calls and BeforeRegister calls %v", registered, emails)
+	}
+}
+
+func TestBeforeLoginCustomClaims(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.BeforeLogin(func(ctx context.Context, user *User, claims *Claims) error {
+		claims.Extra["tenant"] = "acme"
+		return nil
+	})
+
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := client.VerifyToken(resp.Token)
+	if err != nil || claims.Extra["tenant"] != "acme" {
+		t.Fatalf("issued claims = %+v, %v; want the custom claim", claims, err)
+	}
+
+	refreshed, err := client.RefreshToken(ctx, resp.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := client.VerifyToken(refreshed.Token); err != nil || claims.Extra["tenant"] != "acme" {
+		t.Fatalf("refreshed claims = %+v, %v; want the custom claim", claims, err)
+	}
+}
+
+func TestBeforeLoginRejects(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, &ClientOptions{SessionIdleTimeout: time.Hour})
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.BeforeLogin(func(ctx context.Context, user *User, claims *Claims) error {
+		return errors.New("account suspended")
+	})
+	afterLogin := 0
+	client.AfterLogin(func(ctx context.Context, user *User, resp *LoginResponse) { afterLogin++ })
+
+	var appErr *AppError
+	if _, err := client.Login(ctx, "alice@example.com", "password"); !errors.As(err, &appErr) || appErr.Code != http.StatusForbidden {
+		t.Fatalf("login with a rejecting hook = %v, want 403", err)
+	}
+	if afterLogin != 0 {
+		t.Fatal("AfterLogin ran for a rejected login")
+	}
+}
+
+func TestAfterLogin(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	var users []*User
+	var responses []*LoginResponse
+	client.AfterLogin(func(ctx context.Context, user *User, resp *LoginResponse) {
+		users = append(users, user)
+		responses = append(responses, resp)
+	})
+
+	if _, err := client.Login(ctx, "alice@example.com", "wrong"); !IsInvalidCredentials(err) {
+		t.Fatalf("login with a wrong password = %v", err)
+	}
+	if len(responses) != 0 {
+		t.Fatal("AfterLogin ran for a failed login")
+	}
+
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || responses[0] != resp || users[0].Email != "alice@example.com" {
+		t.Fatalf("AfterLogin calls %+v, want one with the login response", responses)
+	}
+}
+
+func TestBeforeDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+	for _, email := range []string{"alice@example.com", "admin@example.com"} {
+		if _, err := client.Register(ctx, email, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client.BeforeDeleteUser(func(ctx context.Context, user *User) error {
+		if user.Email == "admin@example.com" {
+			return errors.New("cannot delete the admin")
+		}
+		return nil
+	})
+	var deleted []string
+	client.AfterDeleteUser(func(ctx context.Context, user *User) {
+		deleted = append(deleted, user.Email)
+	})
+
+	var appErr *AppError
+	if err := client.DeleteUser(ctx, "admin@example.com"); !errors.As(err, &appErr) || appErr.Code != http.StatusForbidden {
+		t.Fatalf("delete with a rejecting hook = %v, want 403", err)
+	}
+	if _, err := client.Login(ctx, "admin@example.com", "password"); err != nil {
+		t.Fatalf("login after a rejected delete: %v", err)
+	}
+
+	if err := client.DeleteUser(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Login(ctx, "alice@example.com", "password"); !IsInvalidCredentials(err) {
+		t.Fatalf("login after delete = %v, want invalid credentials", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "alice@example.com" {
+		t.Fatalf("AfterDeleteUser calls %v", deleted)
+	}
+}
@@ -0,0 +1,61 @@
+package cloudflare_auth_sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorResponse is the JSON envelope written for failed HTTP requests.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody carries the details of an AppError in an ErrorResponse.
+type ErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteJSON writes v as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes err as a JSON ErrorResponse.
+//
+// The status code and message are taken from AppError.Code and
+// AppError.Message. Other errors are reported as 500 without exposing
+// their details.
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := http.StatusText(status)
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		if appErr.Code >= 400 && appErr.Code <= 599 {
+			status = appErr.Code
+		}
+		if appErr.Message != "" {
+			message = appErr.Message
+		}
+	}
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	var limited *rateLimitedError
+	if errors.As(err, &limited) {
+		setRateLimitHeaders(w.Header(), limited.result)
+	}
+
+	WriteJSON(w, status, ErrorResponse{
+		Error: ErrorBody{
+			Code:    status,
+			Message: message,
+		},
+	})
+}
@@ -2,79 +2,226 @@ package cloudflare_auth_sdk
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	cloudflare "github.com/cloudflare/cloudflare-go/v6"
 	"github.com/cloudflare/cloudflare-go/v6/kv"
+	"github.com/cloudflare/cloudflare-go/v6/packages/pagination"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// kvMaxMetadataSize is the maximum size of the JSON metadata of a key.
+	kvMaxMetadataSize = 1024
+
+	// kvListMaxLimit is the largest page size accepted by the list API.
+	kvListMaxLimit = 1000
+
+	// minKVExpirationTTL is the minimum expiration TTL accepted by Workers KV.
+	minKVExpirationTTL = 60 * time.Second
+
+	// kvKeyNotFoundCode is the Cloudflare API error code of a missing key.
+	// Other 404 responses, such as for a deleted namespace, are failures.
+	kvKeyNotFoundCode = 10009
 )
 
 // KVGet retrieves a value from the KV store.
-func (c *Client) KVGet(ctx context.Context, key string) ([]byte, error) {
+//
+// A missing key is reported as ErrKeyNotFound; other failures wrap
+// ErrKVOperationFailed.
+func (c *Client) KVGet(ctx context.Context, key string) (_ []byte, err error) {
 	const op = "Client.KVGet"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(key))
+	defer func() { endSpan(span, err) }()
 
-	resp, err := c.cfClient.KV.Namespaces.Values.Get(ctx, c.namespaceID, key,
-		kv.NamespaceValueGetParams{
-			AccountID: cloudflare.F(c.accountID),
-		})
+	value, err := c.kvGet(ctx, key)
 	if err != nil {
-		return nil, NewAppError(op, err, fmt.Sprintf("failed to get key: %s", key), 500)
-	}
-	defer resp.Body.Close()
-
-	value, err := readAll(resp.Body)
-	if err != nil {
-		return nil, NewAppError(op, err, fmt.Sprintf("failed to read response for key: %s", key), 500)
+		return nil, kvError(op, err, "failed to get key")
 	}
 
 	return value, nil
 }
 
 // KVSet stores a key-value pair in the KV store.
-func (c *Client) KVSet(ctx context.Context, key string, value []byte, opts *KVWriteOptions) error {
+func (c *Client) KVSet(ctx context.Context, key string, value []byte, opts *KVWriteOptions) (err error) {
 	const op = "Client.KVSet"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(key))
+	defer func() { endSpan(span, err) }()
 
-	params := kv.NamespaceValueUpdateParams{
-		AccountID: cloudflare.F(c.accountID),
-		Value:     cloudflare.F(string(value)),
-	}
-
-	if opts != nil {
-		if opts.ExpirationTTL > 0 {
-			params.ExpirationTTL = cloudflare.F(float64(opts.ExpirationTTL))
-		}
-		if opts.Metadata != "" {
-			params.Metadata = cloudflare.F[any](opts.Metadata)
+	if err := c.kvSet(ctx, key, value, opts); err != nil {
+		if errors.Is(err, ErrMetadataTooLarge) || errors.Is(err, ErrInvalidInput) {
+			return NewAppError(op, err, "invalid write options", 400)
 		}
+		return kvError(op, err, "failed to set key")
 	}
 
-	_, err := c.cfClient.KV.Namespaces.Values.Update(ctx, c.namespaceID, key, params)
-	if err != nil {
-		return NewAppError(op, err, fmt.Sprintf("failed to set key: %s", key), 500)
+	return nil
+}
+
+// KVGetWithMetadata retrieves a value together with its metadata and expiration.
+//
+// The value and the metadata are read from the exact-key endpoints, in
+// parallel and bypassing the local read cache.
+func (c *Client) KVGetWithMetadata(ctx context.Context, key string) (_ *KVEntry, err error) {
+	const op = "Client.KVGetWithMetadata"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(key))
+	defer func() { endSpan(span, err) }()
+
+	entry := &KVEntry{Key: key}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return c.kvCall(gctx, "KV.Get", KVClassRead, kvKeyAttr(key), func(ctx context.Context) error {
+			resp, err := c.cfClient.KV.Namespaces.Values.Get(ctx, c.namespaceID, key,
+				kv.NamespaceValueGetParams{
+					AccountID: cloudflare.F(c.accountID),
+				})
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			entry.Expiration = parseKVExpiration(resp.Header.Get("Expiration"))
+			entry.Value, err = readAll(resp.Body)
+			return err
+		})
+	})
+	g.Go(func() error {
+		return c.kvCall(gctx, "KV.GetMetadata", KVClassRead, kvKeyAttr(key), func(ctx context.Context) error {
+			resp, err := c.cfClient.KV.Namespaces.Metadata.Get(ctx, c.namespaceID, key,
+				kv.NamespaceMetadataGetParams{
+					AccountID: cloudflare.F(c.accountID),
+				})
+			if err != nil {
+				return err
+			}
+			entry.Metadata = *resp
+			return nil
+		})
+	})
+	if err := g.Wait(); err != nil {
+		return nil, kvError(op, err, "failed to get key with metadata")
 	}
 
-	return nil
+	return entry, nil
+}
+
+// parseKVExpiration parses the expiration header of a value read, in Unix
+// seconds; it returns nil if the key does not expire
+func parseKVExpiration(header string) *time.Time {
+	unix, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || unix <= 0 {
+		return nil
+	}
+	expiration := time.Unix(unix, 0)
+	return &expiration
 }
 
 // KVDelete removes a key from the KV store.
-func (c *Client) KVDelete(ctx context.Context, key string) error {
+func (c *Client) KVDelete(ctx context.Context, key string) (err error) {
 	const op = "Client.KVDelete"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(key))
+	defer func() { endSpan(span, err) }()
 
-	_, err := c.cfClient.KV.Namespaces.Values.Delete(ctx, c.namespaceID, key,
-		kv.NamespaceValueDeleteParams{
-			AccountID: cloudflare.F(c.accountID),
-		})
-	if err != nil {
-		return NewAppError(op, err, fmt.Sprintf("failed to delete key: %s", key), 500)
+	if err := c.kvDelete(ctx, key); err != nil {
+		return kvError(op, err, "failed to delete key")
 	}
 
 	return nil
 }
 
 // KVList lists keys in the KV namespace.
-func (c *Client) KVList(ctx context.Context, prefix string, limit int) ([]KVKey, error) {
+//
+// Only the first page of at most limit keys is returned; use KVListPage or
+// KVIterate to list more keys.
+func (c *Client) KVList(ctx context.Context, prefix string, limit int) (_ []KVKey, err error) {
 	const op = "Client.KVList"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(prefix))
+	defer func() { endSpan(span, err) }()
+
+	keys, _, err := c.kvList(ctx, prefix, limit, "")
+	if err != nil {
+		return nil, kvError(op, err, "failed to list keys")
+	}
+
+	return keys, nil
+}
+
+// KVListPage lists one page of keys starting at cursor.
+//
+// Pass an empty cursor for the first page and the returned KVPage.Cursor
+// for the following pages; the cursor is empty after the last page.
+func (c *Client) KVListPage(ctx context.Context, prefix string, limit int, cursor string) (_ *KVPage, err error) {
+	const op = "Client.KVListPage"
+	ctx, span := c.startSpan(ctx, op, kvKeyAttr(prefix))
+	defer func() { endSpan(span, err) }()
+
+	keys, next, err := c.kvList(ctx, prefix, limit, cursor)
+	if err != nil {
+		return nil, kvError(op, err, "failed to list keys")
+	}
+
+	return &KVPage{
+		Keys:   keys,
+		Cursor: next,
+	}, nil
+}
+
+// KVIterate returns an iterator over all keys with the given prefix,
+// fetching pages of pageSize keys lazily as iteration proceeds.
+//
+// Iteration stops after yielding the first error, including context
+// cancellation. The iterator has the shape of iter.Seq2[KVKey, error], so
+// with Go 1.23 or later it can be used with range:
+//
+//	for key, err := range client.KVIterate(ctx, "user:", 1000) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(key.Name)
+//	}
+func (c *Client) KVIterate(ctx context.Context, prefix string, pageSize int) func(yield func(KVKey, error) bool) {
+	return func(yield func(KVKey, error) bool) {
+		cursor := ""
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(KVKey{}, kvError("Client.KVIterate", err, "failed to list keys"))
+				return
+			}
+
+			page, err := c.KVListPage(ctx, prefix, pageSize, cursor)
+			if err != nil {
+				yield(KVKey{}, err)
+				return
+			}
+
+			for _, key := range page.Keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
 
+			// Cloudflare may return empty pages before the last one; only
+			// an empty cursor ends the listing
+			if page.Cursor == "" || page.Cursor == cursor {
+				return
+			}
+			cursor = page.Cursor
+		}
+	}
+}
+
+// kvList fetches a page of keys and returns the cursor of the next page
+func (c *Client) kvList(ctx context.Context, prefix string, limit int, cursor string) ([]KVKey, string, error) {
 	params := kv.NamespaceKeyListParams{
 		AccountID: cloudflare.F(c.accountID),
 	}
@@ -87,39 +234,180 @@ func (c *Client) KVList(ctx context.Context, prefix string, limit int) ([]KVKey,
 		params.Limit = cloudflare.F(float64(limit))
 	}
 
-	resp, err := c.cfClient.KV.Namespaces.Keys.List(ctx, c.namespaceID, params)
+	if cursor != "" {
+		params.Cursor = cloudflare.F(cursor)
+	}
+
+	var resp *pagination.CursorPaginationAfter[kv.Key]
+	err := c.kvCall(ctx, "KV.List", KVClassList, kvKeyAttr(prefix), func(ctx context.Context) error {
+		var err error
+		resp, err = c.cfClient.KV.Namespaces.Keys.List(ctx, c.namespaceID, params)
+		return err
+	})
 	if err != nil {
-		return nil, NewAppError(op, err, "failed to list keys", 500)
+		return nil, "", err
 	}
 
 	var keys []KVKey
 	for _, item := range resp.Result {
-		keys = append(keys, KVKey{
-			Name:       item.Name,
-			Expiration: item.Expiration,
-			Metadata:   item.Metadata,
-		})
+		key := KVKey{
+			Name:     item.Name,
+			Metadata: item.Metadata,
+		}
+		if item.Expiration > 0 {
+			expiration := time.Unix(int64(item.Expiration), 0)
+			key.Expiration = &expiration
+		}
+		keys = append(keys, key)
 	}
 
-	return keys, nil
+	return keys, nextListCursor(resp.ResultInfo), nil
+}
+
+// nextListCursor extracts the next page cursor from a list response.
+//
+// The KV API reports it as result_info.cursor, which the generated
+// pagination type does not map, so the raw JSON is consulted as well.
+func nextListCursor(info pagination.CursorPaginationAfterResultInfo) string {
+	if info.Cursors.After != "" {
+		return info.Cursors.After
+	}
+
+	var raw struct {
+		Cursor string `json:"cursor"`
+	}
+	if err := json.Unmarshal([]byte(info.JSON.RawJSON()), &raw); err != nil {
+		return ""
+	}
+	return raw.Cursor
 }
 
 // KVDeleteBulk deletes multiple keys from the KV store.
-func (c *Client) KVDeleteBulk(ctx context.Context, keys []string) error {
+func (c *Client) KVDeleteBulk(ctx context.Context, keys []string) (err error) {
 	const op = "Client.KVDeleteBulk"
+	ctx, span := c.startSpan(ctx, op, attrKVKeyCount.Int(len(keys)))
+	defer func() { endSpan(span, err) }()
 
-	_, err := c.cfClient.KV.Namespaces.Keys.BulkDelete(ctx, c.namespaceID,
-		kv.NamespaceKeyBulkDeleteParams{
-			AccountID: cloudflare.F(c.accountID),
-			Body:      keys,
-		})
-	if err != nil {
-		return NewAppError(op, err, "failed to delete keys in bulk", 500)
+	if err := c.kvDeleteBulk(ctx, keys); err != nil {
+		return kvError(op, err, "failed to delete keys in bulk")
 	}
 
 	return nil
 }
 
+// validateKVExpiration enforces the 60-second minimum expiration of Workers KV
+// and rejects setting both a TTL and an absolute expiration
+func validateKVExpiration(ttl int, expiration, now time.Time) error {
+	minTTL := int(minKVExpirationTTL / time.Second)
+	switch {
+	case ttl != 0 && !expiration.IsZero():
+		return fmt.Errorf("%w: set either an expiration TTL or an expiration time, not both", ErrInvalidInput)
+	case ttl < 0:
+		return fmt.Errorf("%w: negative expiration TTL", ErrInvalidInput)
+	case ttl > 0 && ttl < minTTL:
+		return fmt.Errorf("%w: expiration TTL must be at least %d seconds, got %d", ErrInvalidInput, minTTL, ttl)
+	case ttl == 0 && !expiration.IsZero() && expiration.Sub(now) < minKVExpirationTTL:
+		return fmt.Errorf("%w: expiration must be at least %d seconds in the future", ErrInvalidInput, minTTL)
+	}
+	return nil
+}
+
+// encodeKVMetadata encodes metadata as JSON and enforces Cloudflare's size limit
+func encodeKVMetadata(metadata interface{}) ([]byte, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata is not JSON-serializable: %v", ErrInvalidInput, err)
+	}
+	if len(data) > kvMaxMetadataSize {
+		return nil, fmt.Errorf("%w: got %d bytes", ErrMetadataTooLarge, len(data))
+	}
+	return data, nil
+}
+
+// kvError converts a failed KV call into an AppError.
+//
+// A missing key becomes ErrKeyNotFound (404). Other failures wrap
+// ErrKVOperationFailed: 503 when the failure is likely transient (timeouts,
+// network errors, 429 and 5xx overload responses, an open circuit breaker),
+// 502 otherwise.
+func kvError(op string, err error, message string) *AppError {
+	if isKVNotFound(err) {
+		return NewAppError(op, ErrKeyNotFound, message, 404)
+	}
+
+	code := http.StatusBadGateway
+	if isKVTransient(err) {
+		code = http.StatusServiceUnavailable
+	}
+	return NewAppError(op, fmt.Errorf("%w: %w", ErrKVOperationFailed, &kvCallError{err: err}), message, code)
+}
+
+// kvCallError keeps the request URL, which contains the key, out of the
+// message of a failed KV call. The cause stays available to errors.As.
+type kvCallError struct {
+	err error
+}
+
+func (e *kvCallError) Error() string {
+	var apiErr *cloudflare.Error
+	if errors.As(e.err, &apiErr) {
+		codes := make([]string, len(apiErr.Errors))
+		for i, data := range apiErr.Errors {
+			codes[i] = strconv.FormatInt(data.Code, 10)
+		}
+		return fmt.Sprintf("Cloudflare API answered %d (codes %s)", apiErr.StatusCode, strings.Join(codes, ", "))
+	}
+	var urlErr *url.Error
+	if errors.As(e.err, &urlErr) {
+		return urlErr.Op + " request: " + urlErr.Err.Error()
+	}
+	return e.err.Error()
+}
+
+func (e *kvCallError) Unwrap() error {
+	return e.err
+}
+
+// isKVTransient reports whether a failed KV call may succeed when repeated
+func isKVTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrKVUnavailable) {
+		return true
+	}
+
+	var apiErr *cloudflare.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isKVNotFound reports whether err means the key does not exist. A 404
+// without the "key not found" error code, as returned for a missing
+// namespace or account, is not a missing key.
+func isKVNotFound(err error) bool {
+	if errors.Is(err, ErrKeyNotFound) {
+		return true
+	}
+
+	var apiErr *cloudflare.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return false
+	}
+	for _, e := range apiErr.Errors {
+		if e.Code == kvKeyNotFoundCode {
+			return true
+		}
+	}
+	return false
+}
+
 // readAll is a helper to read all data from an io.Reader
 func readAll(r io.Reader) ([]byte, error) {
 	return io.ReadAll(r)
@@ -0,0 +1,244 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKVIterateFollowsCursorPastEmptyPages(t *testing.T) {
+	client, _ := newTestClient(t, nil)
+
+	// Pages as Cloudflare may return them: empty pages with a cursor
+	// before the keys
+	pages := []struct {
+		keys   []string
+		cursor string
+	}{
+		{nil, "c1"},
+		{[]string{"item:1", "item:2"}, "c2"},
+		{nil, "c3"},
+		{[]string{"item:3"}, ""},
+	}
+	var requests int
+	serveKV(t, client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := 0
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			index, _ = strconv.Atoi(cursor[1:])
+		}
+		requests++
+
+		items := []map[string]string{}
+		for _, key := range pages[index].keys {
+			items = append(items, map[string]string{"name": key})
+		}
+		writeFakeKVJSON(w, http.StatusOK, map[string]interface{}{
+			"success":     true,
+			"errors":      []interface{}{},
+			"messages":    []interface{}{},
+			"result":      items,
+			"result_info": map[string]interface{}{"count": len(items), "cursor": pages[index].cursor},
+		})
+	}))
+
+	var got []string
+	client.KVIterate(context.Background(), "item:", 10)(func(key KVKey, err error) bool {
+		if err != nil {
+			t.Fatalf("KVIterate: %v", err)
+		}
+		got = append(got, key.Name)
+		return true
+	})
+
+	if len(got) != 3 || got[0] != "item:1" || got[2] != "item:3" {
+		t.Fatalf("KVIterate yielded %v, want item:1..item:3", got)
+	}
+	if requests != len(pages) {
+		t.Fatalf("%d list requests, want %d", requests, len(pages))
+	}
+}
+
+func TestKVIterateStopsEarly(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	for i := 0; i < 25; i++ {
+		kv.put("item:"+strconv.Itoa(100+i), []byte("v"))
+	}
+
+	var got int
+	client.KVIterate(ctx, "item:", 10)(func(key KVKey, err error) bool {
+		if err != nil {
+			t.Fatalf("KVIterate: %v", err)
+		}
+		got++
+		return got < 12
+	})
+
+	if got != 12 {
+		t.Fatalf("yielded %d keys after stopping at 12", got)
+	}
+	if n := kv.count("GET keys"); n != 2 {
+		t.Fatalf("%d list requests, want 2 pages fetched lazily", n)
+	}
+}
+
+func TestKVIterateCancelled(t *testing.T) {
+	client, kv := newTestClient(t, nil)
+	kv.put("item:1", []byte("v"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	client.KVIterate(ctx, "item:", 10)(func(key KVKey, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+
+	var appErr *AppError
+	if len(errs) != 1 || !errors.As(errs[0], &appErr) || !errors.Is(errs[0], context.Canceled) {
+		t.Fatalf("KVIterate after cancellation yielded %v, want one AppError wrapping context.Canceled", errs)
+	}
+	if appErr.Op != "Client.KVIterate" {
+		t.Fatalf("error op %q, want Client.KVIterate", appErr.Op)
+	}
+}
+
+func TestKVGetWithMetadataReadsExactKey(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := client.KVSet(ctx, "item:1", []byte("one"), &KVWriteOptions{
+		Expiration: expiration,
+		Metadata:   map[string]string{"kind": "exact"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A key the exact one is a prefix of, which sorts after it in a listing
+	if err := client.KVSet(ctx, "item:10", []byte("ten"), &KVWriteOptions{Metadata: map[string]string{"kind": "other"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := client.KVGetWithMetadata(ctx, "item:1")
+	if err != nil {
+		t.Fatalf("KVGetWithMetadata: %v", err)
+	}
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	if err := entry.DecodeMetadata(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if string(entry.Value) != "one" || meta.Kind != "exact" || entry.Expiration == nil || !entry.Expiration.Equal(expiration) {
+		t.Fatalf("entry = %q, %+v, %v; want one, exact, %v", entry.Value, meta, entry.Expiration, expiration)
+	}
+
+	entry, err = client.KVGetWithMetadata(ctx, "item:10")
+	if err != nil || entry.Metadata == nil || entry.Expiration != nil {
+		t.Fatalf("KVGetWithMetadata(item:10) = %+v, %v", entry, err)
+	}
+
+	if _, err := client.KVGetWithMetadata(ctx, "item:2"); !IsKeyNotFound(err) {
+		t.Fatalf("KVGetWithMetadata(missing) = %v, want key not found", err)
+	}
+	if n := kv.count("GET keys"); n != 0 {
+		t.Fatalf("%d list requests, want none", n)
+	}
+}
+
+func TestValidateKVExpiration(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		ttl        int
+		expiration time.Time
+		wantErr    bool
+	}{
+		{name: "none"},
+		{name: "ttl", ttl: 60},
+		{name: "expiration", expiration: now.Add(time.Hour)},
+		{name: "negative ttl", ttl: -1, wantErr: true},
+		{name: "short ttl", ttl: 59, wantErr: true},
+		{name: "near expiration", expiration: now.Add(30 * time.Second), wantErr: true},
+		{name: "both", ttl: 3600, expiration: now.Add(time.Hour), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKVExpiration(tt.ttl, tt.expiration, now)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidInput)) {
+				t.Fatalf("validateKVExpiration(%d, %v) = %v, want error %v", tt.ttl, tt.expiration, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKVWritesRejectBothExpirations(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	expiration := time.Now().Add(time.Hour)
+
+	err := client.KVSet(ctx, "item:1", []byte("v"), &KVWriteOptions{ExpirationTTL: 3600, Expiration: expiration})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KVSet with both expirations = %v, want ErrInvalidInput", err)
+	}
+	_, err = client.KVSetBulk(ctx, []KVPair{{Key: "item:1", Value: []byte("v"), ExpirationTTL: 3600, Expiration: expiration}})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KVSetBulk with both expirations = %v, want ErrInvalidInput", err)
+	}
+	if n := kv.count("PUT values") + kv.count("PUT bulk"); n != 0 {
+		t.Fatalf("%d write requests, want none", n)
+	}
+}
+
+func TestKVKeyExpirationJSON(t *testing.T) {
+	expiration := time.Unix(1900000000, 0).UTC()
+
+	tests := []struct {
+		key  KVKey
+		want string
+	}{
+		{KVKey{Name: "item:1"}, `{"name":"item:1"}`},
+		{KVKey{Name: "item:2", Expiration: &expiration}, `{"name":"item:2","expiration":"2030-03-17T17:46:40Z"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.key)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("json.Marshal(%+v) = %s, %v; want %s", tt.key, data, err, tt.want)
+		}
+	}
+}
+
+func TestKVErrorMessagesOmitKeys(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	const key = "user:email:alice@example.com"
+
+	_, getErr := client.KVGet(ctx, key)
+	setErr := client.KVSet(ctx, key, []byte("v"), &KVWriteOptions{ExpirationTTL: 1})
+	_, bulkErr := client.KVSetBulk(ctx, []KVPair{{Key: key, Value: []byte("v"), ExpirationTTL: 1}})
+	kv.fail = func(*http.Request) int { return http.StatusInternalServerError }
+	deleteErr := client.KVDelete(ctx, key)
+
+	for _, err := range []error{getErr, setErr, bulkErr, deleteErr} {
+		var appErr *AppError
+		if !errors.As(err, &appErr) {
+			t.Fatalf("got %v, want an AppError", err)
+		}
+		if strings.Contains(appErr.Message, "alice") {
+			t.Errorf("message %q contains the key", appErr.Message)
+		}
+		if strings.Contains(err.Error(), "alice") {
+			t.Errorf("error %q contains the key", err.Error())
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	cloudflare "github.com/cloudflare/cloudflare-go/v6"
+	"github.com/cloudflare/cloudflare-go/v6/kv"
+)
+
+const (
+	// kvBulkMaxItems is the maximum number of pairs per bulk write request.
+	kvBulkMaxItems = 10000
+
+	// kvBulkMaxBytes is the maximum size of a bulk write request.
+	kvBulkMaxBytes = 100_000_000
+
+	// kvBulkFraming is the size of the brackets around the items of a bulk
+	// write request.
+	kvBulkFraming = 2
+
+	// defaultKVBulkGetConcurrency is the default number of parallel reads in KVGetBulk.
+	defaultKVBulkGetConcurrency = 8
+)
+
+// KVSetBulk writes many key-value pairs using Cloudflare's bulk write API.
+//
+// Pairs are split automatically into requests that respect the API limits of
+// 10,000 pairs and 100 MB per request. Values that are not valid UTF-8 are
+// sent base64 encoded. Every pair is validated before the first request, so
+// an invalid pair fails the call without writing anything.
+//
+// The returned KVBulkResult lists keys Cloudflare could not write; they
+// should be retried. If any key was not written an error is returned along
+// with the partial result.
+func (c *Client) KVSetBulk(ctx context.Context, pairs []KVPair) (_ *KVBulkResult, err error) {
+	const op = "Client.KVSetBulk"
+	ctx, span := c.startSpan(ctx, op, attrKVKeyCount.Int(len(pairs)))
+	defer func() { endSpan(span, err) }()
+
+	// Convert every pair up front, so an invalid pair fails the call before
+	// anything is written
+	items := make([]kvBulkItem, len(pairs))
+	for i := range pairs {
+		if items[i], err = pairs[i].toBulkItem(); err != nil {
+			return nil, NewAppError(op, err, fmt.Sprintf("invalid pair at index %d", i), 400)
+		}
+	}
+
+	result := &KVBulkResult{}
+	for _, chunk := range chunkKVBulkItems(items) {
+		body := make([]kv.NamespaceBulkUpdateParamsBody, len(chunk))
+		keys := make([]string, len(chunk))
+		for i, item := range chunk {
+			body[i] = item.body
+			keys[i] = item.key
+		}
+
+		resp, err := c.kvSetBulk(ctx, keys, body)
+		if err != nil {
+			return result, kvError(op, err, "failed to write keys in bulk")
+		}
+
+		result.SuccessfulKeys += int(resp.SuccessfulKeyCount)
+		result.UnsuccessfulKeys = append(result.UnsuccessfulKeys, resp.UnsuccessfulKeys...)
+	}
+
+	if len(result.UnsuccessfulKeys) > 0 {
+		return result, NewAppError(op, ErrKVOperationFailed,
+			fmt.Sprintf("%d keys were not written", len(result.UnsuccessfulKeys)), 502)
+	}
+
+	return result, nil
+}
+
+// KVGetBulk reads many keys in parallel, with at most concurrency reads in
+// flight (default: 8).
+//
+// Results are returned in the order of keys, each with its own value or
+// error, so a failure for one key does not affect the others.
+func (c *Client) KVGetBulk(ctx context.Context, keys []string, concurrency int) []KVGetResult {
+	ctx, span := c.startSpan(ctx, "Client.KVGetBulk", attrKVKeyCount.Int(len(keys)))
+	defer span.End()
+
+	if concurrency <= 0 {
+		concurrency = defaultKVBulkGetConcurrency
+	}
+
+	results := make([]KVGetResult, len(keys))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, key := range keys {
+		results[i].Key = key
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = kvError("Client.KVGetBulk", ctx.Err(), "failed to get key")
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i].Value, results[i].Err = c.KVGet(ctx, key)
+		}(i, key)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func (c *Client) kvSetBulk(ctx context.Context, keys []string, body []kv.NamespaceBulkUpdateParamsBody) (*kv.NamespaceBulkUpdateResponse, error) {
+	var resp *kv.NamespaceBulkUpdateResponse
+	err := c.kvCall(ctx, "KV.BulkPut", KVClassBulk, attrKVKeyCount.Int(len(keys)), func(ctx context.Context) error {
+		var err error
+		resp, err = c.cfClient.KV.Namespaces.BulkUpdate(ctx, c.namespaceID, kv.NamespaceBulkUpdateParams{
+			AccountID: cloudflare.F(c.accountID),
+			Body:      body,
+		})
+		return err
+	})
+	c.invalidateKV(keys...)
+	return resp, err
+}
+
+// kvBulkItem is a pair converted for a bulk write request
+type kvBulkItem struct {
+	key  string
+	body kv.NamespaceBulkUpdateParamsBody
+	size int // Encoded size in the request, including the separating comma
+}
+
+// toBulkItem converts the pair to a bulk write request item
+func (p *KVPair) toBulkItem() (kvBulkItem, error) {
+	body := kv.NamespaceBulkUpdateParamsBody{
+		Key: cloudflare.F(p.Key),
+	}
+
+	if utf8.Valid(p.Value) {
+		body.Value = cloudflare.F(string(p.Value))
+	} else {
+		body.Value = cloudflare.F(base64.StdEncoding.EncodeToString(p.Value))
+		body.Base64 = cloudflare.F(true)
+	}
+
+	if err := validateKVExpiration(p.ExpirationTTL, p.Expiration, time.Now()); err != nil {
+		return kvBulkItem{}, err
+	}
+	if p.ExpirationTTL > 0 {
+		body.ExpirationTTL = cloudflare.F(float64(p.ExpirationTTL))
+	} else if !p.Expiration.IsZero() {
+		body.Expiration = cloudflare.F(float64(p.Expiration.Unix()))
+	}
+	if p.Metadata != nil {
+		metadata, err := encodeKVMetadata(p.Metadata)
+		if err != nil {
+			return kvBulkItem{}, err
+		}
+		body.Metadata = cloudflare.F[interface{}](json.RawMessage(metadata))
+	}
+
+	// Measure the encoded item, since JSON escaping can grow a value up to
+	// six times
+	data, err := json.Marshal(body)
+	if err != nil {
+		return kvBulkItem{}, err
+	}
+
+	return kvBulkItem{key: p.Key, body: body, size: len(data) + 1}, nil
+}
+
+// chunkKVBulkItems splits items into groups that fit in one bulk write request
+func chunkKVBulkItems(items []kvBulkItem) [][]kvBulkItem {
+	var chunks [][]kvBulkItem
+	start, size := 0, kvBulkFraming
+
+	for i := range items {
+		if i > start && (i-start >= kvBulkMaxItems || size+items[i].size > kvBulkMaxBytes) {
+			chunks = append(chunks, items[start:i])
+			start, size = i, kvBulkFraming
+		}
+		size += items[i].size
+	}
+
+	if start < len(items) {
+		chunks = append(chunks, items[start:])
+	}
+
+	return chunks
+}
@@ -0,0 +1,186 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChunkKVBulkItems(t *testing.T) {
+	sized := func(sizes ...int) []kvBulkItem {
+		items := make([]kvBulkItem, len(sizes))
+		for i, size := range sizes {
+			items[i] = kvBulkItem{key: fmt.Sprintf("item:%d", i), size: size}
+		}
+		return items
+	}
+	many := make([]int, kvBulkMaxItems+1)
+	for i := range many {
+		many[i] = 10
+	}
+
+	tests := []struct {
+		name  string
+		items []kvBulkItem
+		want  []int // Chunk lengths
+	}{
+		{name: "empty", items: nil, want: nil},
+		{name: "one chunk", items: sized(10, 20, 30), want: []int{3}},
+		{name: "item limit", items: sized(many...), want: []int{kvBulkMaxItems, 1}},
+		{name: "byte limit", items: sized(40_000_000, 40_000_000, 40_000_000), want: []int{2, 1}},
+		{name: "exactly full", items: sized(kvBulkMaxBytes/2-1, kvBulkMaxBytes/2-1, 1), want: []int{2, 1}},
+		{name: "oversized item alone", items: sized(10, kvBulkMaxBytes+1, 10), want: []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkKVBulkItems(tt.items)
+
+			var lengths []int
+			next := 0
+			for _, chunk := range chunks {
+				lengths = append(lengths, len(chunk))
+				for _, item := range chunk {
+					if item.key != tt.items[next].key {
+						t.Fatalf("item %q out of order, want %q", item.key, tt.items[next].key)
+					}
+					next++
+				}
+			}
+			if fmt.Sprint(lengths) != fmt.Sprint(tt.want) {
+				t.Fatalf("chunk lengths %v, want %v", lengths, tt.want)
+			}
+		})
+	}
+}
+
+func TestKVBulkItemSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{name: "text", value: []byte("hello")},
+		{name: "control characters", value: []byte(strings.Repeat("\x01", 100))},
+		{name: "binary", value: []byte{0xff, 0xfe, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair := KVPair{Key: "item:1", Value: tt.value, Metadata: map[string]string{"a": "b"}}
+			item, err := pair.toBulkItem()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal([]interface{}{item.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The item size includes its share of the separating commas
+			if want := len(data) - kvBulkFraming + 1; item.size != want {
+				t.Fatalf("size %d, want the encoded size %d", item.size, want)
+			}
+		})
+	}
+}
+
+func TestKVSetBulkValidatesBeforeWriting(t *testing.T) {
+	client, kv := newTestClient(t, nil)
+
+	pairs := make([]KVPair, kvBulkMaxItems+1)
+	for i := range pairs {
+		pairs[i] = KVPair{Key: fmt.Sprintf("item:%d", i), Value: []byte("v")}
+	}
+	pairs[len(pairs)-1].ExpirationTTL = 1 // Below the minimum, in the second chunk
+
+	_, err := client.KVSetBulk(context.Background(), pairs)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("KVSetBulk with an invalid pair = %v, want ErrInvalidInput", err)
+	}
+	if n := kv.count("PUT bulk"); n != 0 {
+		t.Fatalf("%d bulk writes sent before the invalid pair was rejected", n)
+	}
+	if keys := kv.keys("item:"); len(keys) != 0 {
+		t.Fatalf("%d keys written", len(keys))
+	}
+}
+
+func TestKVGetBulk(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+
+	var keys []string
+	for i := 0; i < 12; i++ {
+		key := fmt.Sprintf("item:%02d", i)
+		keys = append(keys, key)
+		kv.put(key, []byte("value "+key))
+	}
+	keys = append(keys, "item:missing", "item:broken")
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	kv.fail = func(r *http.Request) int {
+		if strings.HasSuffix(r.URL.Path, "/item:broken") {
+			return http.StatusInternalServerError
+		}
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return 0
+	}
+
+	results := client.KVGetBulk(ctx, keys, 3)
+	if len(results) != len(keys) {
+		t.Fatalf("%d results, want %d", len(results), len(keys))
+	}
+	for i, result := range results {
+		if result.Key != keys[i] {
+			t.Fatalf("result %d is for %q, want %q", i, result.Key, keys[i])
+		}
+		switch result.Key {
+		case "item:missing":
+			if !IsKeyNotFound(result.Err) {
+				t.Errorf("missing key error = %v, want ErrKeyNotFound", result.Err)
+			}
+		case "item:broken":
+			if result.Err == nil || IsKeyNotFound(result.Err) {
+				t.Errorf("failing key error = %v, want a KV failure", result.Err)
+			}
+		default:
+			if result.Err != nil || string(result.Value) != "value "+result.Key {
+				t.Errorf("%s = %q, %v", result.Key, result.Value, result.Err)
+			}
+		}
+	}
+
+	if maxActive > 3 || maxActive < 2 {
+		t.Fatalf("at most %d reads in flight, want 2 or 3", maxActive)
+	}
+}
+
+func TestKVGetBulkCancelled(t *testing.T) {
+	client, kv := newTestClient(t, nil)
+	kv.put("item:1", []byte("v"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, result := range client.KVGetBulk(ctx, []string{"item:1", "item:2"}, 1) {
+		if result.Err == nil {
+			t.Errorf("%s read after cancellation", result.Key)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package cloudflare_auth_sdk
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultKVCacheMaxBytes is the default size limit of the KV cache.
+	defaultKVCacheMaxBytes = 16 << 20
+
+	// defaultKVCacheTTL is the default lifetime of cached KV values.
+	defaultKVCacheTTL = time.Minute
+
+	// kvCacheEntryOverhead approximates the per-entry bookkeeping cost in bytes.
+	kvCacheEntryOverhead = 64
+
+	// kvCacheFetchTimeout bounds a shared fetch, which outlives the
+	// cancellation of the caller that started it.
+	kvCacheFetchTimeout = 30 * time.Second
+)
+
+// kvCacheUncachedPrefixes are not cached unless a PrefixTTLs entry matches
+// them. Sessions and users are changed by Logout, DeleteUser and other
+// clients, which a local cache would hide for a whole TTL.
+var kvCacheUncachedPrefixes = []string{"session:", "user:"}
+
+// KVCacheOptions contains options for the local read-through KV cache.
+//
+// The cache is local to the client: writes and deletes made through the
+// client invalidate cached keys immediately, while changes made elsewhere
+// become visible once the cached entry expires.
+//
+// Caching is safe for keys that change rarely or only through this client,
+// such as application configuration. Of the SDK's own key families:
+//
+//   - "session:" and "user:" keys are read through the cache but not cached
+//     by default, so that logouts and deletions made by other instances
+//     take effect at once. Add PrefixTTLs entries for them to opt in.
+//   - "apikey:", "serviceaccount:", "ratelimit:", "audit:" and "webhook:"
+//     keys are always read past the cache by the SDK, since revocations,
+//     counters and delivery state must not be served stale; PrefixTTLs do
+//     not apply to those reads.
+type KVCacheOptions struct {
+	MaxBytes    int64         // Maximum size of cached keys and values (default: 16 MiB)
+	TTL         time.Duration // Lifetime of cached values (default: 1 minute)
+	NegativeTTL time.Duration // Lifetime of cached "key not found" results (default: disabled)
+
+	// PrefixTTLs overrides TTL for keys starting with a prefix; the longest
+	// matching prefix wins. A zero duration disables caching for the prefix,
+	// and a positive one enables it for "session:" and "user:" keys.
+	PrefixTTLs map[string]time.Duration
+}
+
+// KVCacheStats contains KV cache statistics.
+type KVCacheStats struct {
+	Hits         uint64 // Reads served from cached values
+	NegativeHits uint64 // Reads served from cached "key not found" results
+	Misses       uint64 // Reads that went to Cloudflare
+	Evictions    uint64 // Entries evicted to stay within MaxBytes
+	Entries      int    // Entries currently cached
+	Bytes        int64  // Approximate size of cached entries
+}
+
+// kvCache is a size-bounded LRU cache of KV values with per-entry expiry.
+type kvCache struct {
+	opts  KVCacheOptions
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently used
+	bytes   int64
+
+	// fetches tracks the keys being fetched. Invalidating such a key bumps
+	// its generation so that a fetch started before a write does not
+	// repopulate the cache with a stale value; other fetches are unaffected.
+	fetches map[string]*kvCacheFetch
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+// kvCacheFetch counts the in-flight fetches of a key and its invalidations
+type kvCacheFetch struct {
+	count      int
+	generation uint64
+}
+
+type kvCacheEntry struct {
+	key       string
+	value     []byte // nil for a cached "key not found" result
+	size      int64
+	expiresAt time.Time
+}
+
+func newKVCache(opts *KVCacheOptions) *kvCache {
+	cacheOpts := *opts
+	if cacheOpts.MaxBytes == 0 {
+		cacheOpts.MaxBytes = defaultKVCacheMaxBytes
+	}
+	if cacheOpts.TTL == 0 {
+		cacheOpts.TTL = defaultKVCacheTTL
+	}
+
+	return &kvCache{
+		opts:    cacheOpts,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		fetches: make(map[string]*kvCacheFetch),
+	}
+}
+
+// get reads a key through the cache, deduplicating concurrent misses
+func (kc *kvCache) get(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	now := time.Now()
+	if value, found, ok := kc.lookup(key, now); ok {
+		if !found {
+			kc.negativeHits.Add(1)
+			return nil, ErrKeyNotFound
+		}
+		kc.hits.Add(1)
+		return value, nil
+	}
+
+	kc.misses.Add(1)
+	resultCh := kc.group.DoChan(key, func() (interface{}, error) {
+		// The fetch is shared with concurrent callers, so the cancellation of
+		// the caller that started it must not fail the others
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), kvCacheFetchTimeout)
+		defer cancel()
+
+		generation := kc.startFetch(key)
+		value, err := fetch(fetchCtx)
+		switch {
+		case err == nil:
+			kc.store(key, value, generation, false)
+		case isKVNotFound(err):
+			kc.store(key, nil, generation, true)
+		default:
+			kc.mu.Lock()
+			kc.finishFetch(key, generation)
+			kc.mu.Unlock()
+		}
+		return value, err
+	})
+
+	select {
+	case result := <-resultCh:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return cloneBytes(result.Val.([]byte)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookup returns the cached value; found is false for a cached miss and ok
+// is false when the key is not cached
+func (kc *kvCache) lookup(key string, now time.Time) (value []byte, found, ok bool) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	elem, ok := kc.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+
+	entry := elem.Value.(*kvCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		kc.removeElement(elem)
+		return nil, false, false
+	}
+
+	kc.order.MoveToFront(elem)
+	if entry.value == nil {
+		return nil, false, true
+	}
+	return cloneBytes(entry.value), true, true
+}
+
+// startFetch registers a fetch of key and returns the key's generation
+func (kc *kvCache) startFetch(key string) uint64 {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	f, ok := kc.fetches[key]
+	if !ok {
+		f = &kvCacheFetch{}
+		kc.fetches[key] = f
+	}
+	f.count++
+	return f.generation
+}
+
+// finishFetch unregisters a fetch of key and reports whether the key was
+// not invalidated since the fetch started; kc.mu must be held
+func (kc *kvCache) finishFetch(key string, generation uint64) bool {
+	f := kc.fetches[key]
+	current := f.generation == generation
+	if f.count--; f.count == 0 {
+		delete(kc.fetches, key)
+	}
+	return current
+}
+
+// store caches a fetched value unless the key was invalidated since the fetch began
+func (kc *kvCache) store(key string, value []byte, generation uint64, notFound bool) {
+	ttl, cacheable := kc.ttlFor(key, notFound)
+
+	entry := &kvCacheEntry{
+		key:       key,
+		size:      int64(len(key) + len(value) + kvCacheEntryOverhead),
+		expiresAt: time.Now().Add(ttl),
+	}
+	if !notFound {
+		entry.value = cloneBytes(value)
+		if entry.value == nil {
+			entry.value = []byte{}
+		}
+	}
+
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if !kc.finishFetch(key, generation) || !cacheable || entry.size > kc.opts.MaxBytes {
+		return
+	}
+
+	if elem, ok := kc.entries[key]; ok {
+		kc.removeElement(elem)
+	}
+
+	kc.entries[key] = kc.order.PushFront(entry)
+	kc.bytes += entry.size
+
+	for kc.bytes > kc.opts.MaxBytes {
+		kc.removeElement(kc.order.Back())
+		kc.evictions.Add(1)
+	}
+}
+
+// invalidate removes keys from the cache after a write or delete
+func (kc *kvCache) invalidate(keys ...string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	for _, key := range keys {
+		if f, ok := kc.fetches[key]; ok {
+			f.generation++
+		}
+		kc.group.Forget(key)
+		if elem, ok := kc.entries[key]; ok {
+			kc.removeElement(elem)
+		}
+	}
+}
+
+// ttlFor returns the lifetime of a cached entry for the key
+func (kc *kvCache) ttlFor(key string, notFound bool) (time.Duration, bool) {
+	ttl := kc.opts.TTL
+	longest := -1
+	for prefix, prefixTTL := range kc.opts.PrefixTTLs {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			ttl = prefixTTL
+			longest = len(prefix)
+		}
+	}
+
+	if longest < 0 {
+		for _, prefix := range kvCacheUncachedPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return 0, false
+			}
+		}
+	}
+
+	if ttl <= 0 {
+		return 0, false
+	}
+
+	if notFound {
+		if kc.opts.NegativeTTL <= 0 {
+			return 0, false
+		}
+		if kc.opts.NegativeTTL < ttl {
+			ttl = kc.opts.NegativeTTL
+		}
+	}
+
+	return ttl, true
+}
+
+func (kc *kvCache) removeElement(elem *list.Element) {
+	entry := kc.order.Remove(elem).(*kvCacheEntry)
+	delete(kc.entries, entry.key)
+	kc.bytes -= entry.size
+}
+
+func (kc *kvCache) stats() KVCacheStats {
+	kc.mu.Lock()
+	entries, bytes := len(kc.entries), kc.bytes
+	kc.mu.Unlock()
+
+	return KVCacheStats{
+		Hits:         kc.hits.Load(),
+		NegativeHits: kc.negativeHits.Load(),
+		Misses:       kc.misses.Load(),
+		Evictions:    kc.evictions.Load(),
+		Entries:      entries,
+		Bytes:        bytes,
+	}
+}
+
+// KVCacheStats returns statistics of the local KV cache.
+//
+// All values are zero when the cache is disabled.
+func (c *Client) KVCacheStats() KVCacheStats {
+	if c.kvCache == nil {
+		return KVCacheStats{}
+	}
+	return c.kvCache.stats()
+}
+
+// cloneBytes returns a copy of b so callers cannot modify cached data
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
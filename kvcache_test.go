@@ -0,0 +1,171 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKVCacheTTLFor(t *testing.T) {
+	opts := &KVCacheOptions{
+		TTL:         time.Minute,
+		NegativeTTL: 10 * time.Second,
+		PrefixTTLs: map[string]time.Duration{
+			"config:":       10 * time.Minute,
+			"config:flags:": 0,
+			"user:id:":      30 * time.Second,
+		},
+	}
+	kc := newKVCache(opts)
+
+	tests := []struct {
+		key       string
+		notFound  bool
+		wantTTL   time.Duration
+		cacheable bool
+	}{
+		{key: "item:1", wantTTL: time.Minute, cacheable: true},
+		{key: "item:1", notFound: true, wantTTL: 10 * time.Second, cacheable: true},
+		{key: "config:db", wantTTL: 10 * time.Minute, cacheable: true},
+		{key: "config:flags:beta", cacheable: false},
+		{key: "session:abc", cacheable: false},
+		{key: "user:email:alice@example.com", cacheable: false},
+		{key: "user:id:u1", wantTTL: 30 * time.Second, cacheable: true},
+	}
+
+	for _, tt := range tests {
+		ttl, ok := kc.ttlFor(tt.key, tt.notFound)
+		if ok != tt.cacheable || (ok && ttl != tt.wantTTL) {
+			t.Errorf("ttlFor(%q, %v) = %v, %v; want %v, %v", tt.key, tt.notFound, ttl, ok, tt.wantTTL, tt.cacheable)
+		}
+	}
+
+	// Negative results are not cached without NegativeTTL
+	kc = newKVCache(&KVCacheOptions{})
+	if _, ok := kc.ttlFor("item:1", true); ok {
+		t.Error("not-found result cacheable without NegativeTTL")
+	}
+}
+
+func TestKVCacheSharedFetchSurvivesCancellation(t *testing.T) {
+	kc := newKVCache(&KVCacheOptions{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var fetches atomic.Int32
+
+	fetch := func(ctx context.Context) ([]byte, error) {
+		if fetches.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte("value"), nil
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := kc.get(firstCtx, "item:1", fetch)
+		firstErr <- err
+	}()
+	<-started
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	second := make(chan result, 1)
+	go func() {
+		value, err := kc.get(context.Background(), "item:1", fetch)
+		second <- result{value, err}
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the second caller join the fetch
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	res := <-second
+	if res.err != nil || string(res.value) != "value" {
+		t.Fatalf("waiting caller got %q, %v; want the fetched value", res.value, res.err)
+	}
+
+	value, err := kc.get(context.Background(), "item:1", fetch)
+	if err != nil || string(value) != "value" {
+		t.Fatalf("cached read = %q, %v", value, err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+}
+
+func TestKVCacheInvalidateDuringFetch(t *testing.T) {
+	kc := newKVCache(&KVCacheOptions{})
+
+	_, err := kc.get(context.Background(), "item:1", func(context.Context) ([]byte, error) {
+		kc.invalidate("item:1") // A write lands while the read is in flight
+		return []byte("stale"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, ok := kc.lookup("item:1", time.Now()); ok {
+		t.Fatal("value fetched before an invalidation was cached")
+	}
+}
+
+func TestKVCacheInvalidateOtherKeyDuringFetch(t *testing.T) {
+	kc := newKVCache(&KVCacheOptions{})
+
+	_, err := kc.get(context.Background(), "item:1", func(context.Context) ([]byte, error) {
+		kc.invalidate("item:2") // A write to another key lands while the read is in flight
+		return []byte("value"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, _, ok := kc.lookup("item:1", time.Now()); !ok || string(value) != "value" {
+		t.Fatalf("lookup = %q, %v; want the fetched value cached", value, ok)
+	}
+	if len(kc.fetches) != 0 {
+		t.Fatalf("%d fetches still registered", len(kc.fetches))
+	}
+}
+
+func TestKVCacheDoesNotCacheSessionsByDefault(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{
+		KVCache:            &KVCacheOptions{TTL: time.Hour},
+		SessionIdleTimeout: time.Hour,
+	})
+
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateToken(ctx, resp.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance logs the session out
+	for _, key := range kv.keys("session:") {
+		kv.mu.Lock()
+		delete(kv.entries, key)
+		kv.mu.Unlock()
+	}
+
+	if _, err := client.ValidateToken(ctx, resp.Token); !IsSessionExpired(err) {
+		t.Fatalf("ValidateToken after a remote logout = %v, want session expired", err)
+	}
+}
@@ -0,0 +1,85 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type testProfile struct {
+	DisplayName string `json:"display_name"`
+	Age         int    `json:"age"`
+}
+
+func TestKVJSONRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+
+	want := testProfile{DisplayName: "Ada", Age: 36}
+	if err := KVSetJSON(ctx, client, "profile:1", want, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := kv.get("profile:1"); string(data) != `{"display_name":"Ada","age":36}` {
+		t.Fatalf("stored %s, want plain JSON", data)
+	}
+
+	got, err := KVGetJSON[testProfile](ctx, client, "profile:1")
+	if err != nil || got != want {
+		t.Fatalf("KVGetJSON = %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestKVGetJSONErrors(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	kv.put("profile:bad", []byte(`{"display_name": 42}`))
+
+	_, err := KVGetJSON[testProfile](ctx, client, "profile:missing")
+	if !IsKeyNotFound(err) {
+		t.Fatalf("missing key = %v, want ErrKeyNotFound", err)
+	}
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr.Op != "Client.KVGetJSON" || appErr.Code != 404 {
+		t.Fatalf("missing key error = %#v, want a 404 from Client.KVGetJSON", err)
+	}
+
+	if _, err := KVGetJSON[testProfile](ctx, client, "profile:bad"); !errors.Is(err, ErrDecodeFailed) {
+		t.Fatalf("undecodable value = %v, want ErrDecodeFailed", err)
+	}
+}
+
+func TestKVJSONSchemaVersions(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	RegisterKVSchema[testProfile](client, 2)
+
+	want := testProfile{DisplayName: "Ada"}
+	if err := KVSetJSON(ctx, client, "profile:1", want, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := kv.get("profile:1"); string(data) != `{"schema_version":2,"data":{"display_name":"Ada","age":0}}` {
+		t.Fatalf("stored %s, want a version 2 envelope", data)
+	}
+	if got, err := KVGetJSON[testProfile](ctx, client, "profile:1"); err != nil || got != want {
+		t.Fatalf("KVGetJSON = %+v, %v; want %+v", got, err, want)
+	}
+
+	// A value written with another version, or without an envelope
+	kv.put("profile:old", []byte(`{"schema_version":1,"data":{"display_name":"Ada"}}`))
+	if _, err := KVGetJSON[testProfile](ctx, client, "profile:old"); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("version 1 value = %v, want ErrSchemaMismatch", err)
+	}
+	kv.put("profile:plain", []byte(`"not an envelope"`))
+	if _, err := KVGetJSON[testProfile](ctx, client, "profile:plain"); !errors.Is(err, ErrDecodeFailed) {
+		t.Fatalf("value without an envelope = %v, want ErrDecodeFailed", err)
+	}
+
+	// Registrations are scoped to the client
+	other, otherKV := newTestClient(t, nil)
+	if err := KVSetJSON(ctx, other, "profile:1", want, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := otherKV.get("profile:1"); string(data) != `{"display_name":"Ada","age":0}` {
+		t.Fatalf("other client stored %s, want plain JSON", data)
+	}
+}
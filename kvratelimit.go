@@ -0,0 +1,195 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// errKVThrottled reports a KV request that could not start before its
+// context deadline because of the client-side rate limit.
+var errKVThrottled = errors.New("KV request throttled by client-side rate limit")
+
+// KVOperationClass groups KV requests for rate limiting.
+type KVOperationClass int
+
+const (
+	// KVClassRead covers single key reads.
+	KVClassRead KVOperationClass = iota
+	// KVClassWrite covers single key writes and deletes.
+	KVClassWrite
+	// KVClassList covers key listing.
+	KVClassList
+	// KVClassBulk covers bulk writes and deletes.
+	KVClassBulk
+)
+
+// String returns the name of the class.
+func (k KVOperationClass) String() string {
+	switch k {
+	case KVClassRead:
+		return "read"
+	case KVClassWrite:
+		return "write"
+	case KVClassList:
+		return "list"
+	case KVClassBulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+// KVRateLimit is a token bucket refilled at RequestsPerSecond up to Burst tokens.
+type KVRateLimit struct {
+	RequestsPerSecond float64
+	Burst             int // Maximum tokens (default: 1)
+}
+
+// KVRateLimitOptions contains client-side rate limits for KV requests.
+//
+// Every request takes a token from the limit of its class and from Total;
+// a nil limit does not restrict requests. Requests wait for tokens until
+// their context is done, and fail right away when the wait would outlast the
+// context deadline. Retries take tokens like first attempts; to that end
+// the Cloudflare client's built-in retries, which would bypass the limiter,
+// are replaced by the default RetryPolicy unless ClientOptions.Retry is set.
+//
+// Cloudflare allows 1,200 API requests per five minutes per user across
+// all clients, so Total should account for other processes sharing the
+// credentials.
+type KVRateLimitOptions struct {
+	Read  *KVRateLimit
+	Write *KVRateLimit
+	List  *KVRateLimit
+	Bulk  *KVRateLimit
+	Total *KVRateLimit
+}
+
+// KVRateLimitStats contains rate limiting statistics of one operation class.
+type KVRateLimitStats struct {
+	Requests      uint64        // Requests that passed the limiter
+	Throttled     uint64        // Requests that had to wait
+	ThrottledTime time.Duration // Total time requests spent waiting
+}
+
+// kvRateLimiter holds the token buckets shared by all goroutines using a client.
+type kvRateLimiter struct {
+	classes map[KVOperationClass]*rate.Limiter
+	total   *rate.Limiter
+
+	mu    sync.Mutex
+	stats map[KVOperationClass]KVRateLimitStats
+}
+
+func newKVRateLimiter(opts *KVRateLimitOptions) *kvRateLimiter {
+	rl := &kvRateLimiter{
+		classes: make(map[KVOperationClass]*rate.Limiter),
+		total:   newLimiter(opts.Total),
+		stats:   make(map[KVOperationClass]KVRateLimitStats),
+	}
+
+	for class, limit := range map[KVOperationClass]*KVRateLimit{
+		KVClassRead:  opts.Read,
+		KVClassWrite: opts.Write,
+		KVClassList:  opts.List,
+		KVClassBulk:  opts.Bulk,
+	} {
+		if limiter := newLimiter(limit); limiter != nil {
+			rl.classes[class] = limiter
+		}
+	}
+
+	return rl
+}
+
+func newLimiter(limit *KVRateLimit) *rate.Limiter {
+	if limit == nil {
+		return nil
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), burst)
+}
+
+// wait blocks until a request of the class may start
+func (rl *kvRateLimiter) wait(ctx context.Context, class KVOperationClass) error {
+	var reservations []*rate.Reservation
+	cancel := func() {
+		for _, r := range reservations {
+			r.Cancel()
+		}
+	}
+
+	now := time.Now()
+	var delay time.Duration
+	for _, limiter := range []*rate.Limiter{rl.classes[class], rl.total} {
+		if limiter == nil {
+			continue
+		}
+		r := limiter.ReserveN(now, 1)
+		if !r.OK() {
+			cancel()
+			return fmt.Errorf("%w: burst is zero", errKVThrottled)
+		}
+		reservations = append(reservations, r)
+		if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+			cancel()
+			return fmt.Errorf("%w: %w", errKVThrottled, context.DeadlineExceeded)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			cancel()
+			return ctx.Err()
+		}
+	}
+
+	rl.mu.Lock()
+	stats := rl.stats[class]
+	stats.Requests++
+	if delay > 0 {
+		stats.Throttled++
+		stats.ThrottledTime += delay
+	}
+	rl.stats[class] = stats
+	rl.mu.Unlock()
+
+	return nil
+}
+
+func (rl *kvRateLimiter) snapshot() map[KVOperationClass]KVRateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	stats := make(map[KVOperationClass]KVRateLimitStats, len(rl.stats))
+	for class, s := range rl.stats {
+		stats[class] = s
+	}
+	return stats
+}
+
+// KVRateLimitStats returns rate limiting statistics per operation class.
+//
+// The map is empty when rate limiting is disabled.
+func (c *Client) KVRateLimitStats() map[KVOperationClass]KVRateLimitStats {
+	if c.rateLimiter == nil {
+		return map[KVOperationClass]KVRateLimitStats{}
+	}
+	return c.rateLimiter.snapshot()
+}
@@ -0,0 +1,146 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKVRateLimiterTokenBucket(t *testing.T) {
+	type request struct {
+		class     KVOperationClass
+		wantDelay bool
+	}
+
+	tests := []struct {
+		name     string
+		opts     KVRateLimitOptions
+		requests []request
+	}{
+		{
+			name: "burst passes then waits",
+			opts: KVRateLimitOptions{Read: &KVRateLimit{RequestsPerSecond: 20, Burst: 2}},
+			requests: []request{
+				{KVClassRead, false},
+				{KVClassRead, false},
+				{KVClassRead, true},
+			},
+		},
+		{
+			name: "default burst is one",
+			opts: KVRateLimitOptions{Write: &KVRateLimit{RequestsPerSecond: 20}},
+			requests: []request{
+				{KVClassWrite, false},
+				{KVClassWrite, true},
+			},
+		},
+		{
+			name: "classes have separate buckets",
+			opts: KVRateLimitOptions{Read: &KVRateLimit{RequestsPerSecond: 20}},
+			requests: []request{
+				{KVClassRead, false},
+				{KVClassWrite, false},
+				{KVClassList, false},
+				{KVClassRead, true},
+			},
+		},
+		{
+			name: "total is shared by all classes",
+			opts: KVRateLimitOptions{Total: &KVRateLimit{RequestsPerSecond: 20, Burst: 2}},
+			requests: []request{
+				{KVClassRead, false},
+				{KVClassBulk, false},
+				{KVClassWrite, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newKVRateLimiter(&tt.opts)
+
+			for i, req := range tt.requests {
+				start := time.Now()
+				if err := rl.wait(context.Background(), req.class); err != nil {
+					t.Fatalf("request %d: %v", i, err)
+				}
+				delayed := time.Since(start) >= 25*time.Millisecond
+				if delayed != req.wantDelay {
+					t.Fatalf("request %d (%v): waited %v, want delay %v", i, req.class, time.Since(start), req.wantDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestKVRateLimiterDeadline(t *testing.T) {
+	rl := newKVRateLimiter(&KVRateLimitOptions{Read: &KVRateLimit{RequestsPerSecond: 1}})
+	if err := rl.wait(context.Background(), KVClassRead); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next token is a second away, beyond the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := rl.wait(ctx, KVClassRead)
+	if !errors.Is(err, errKVThrottled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait = %v, want errKVThrottled and DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Fatalf("wait blocked %v before failing", elapsed)
+	}
+
+	// The failed request returned its reservation, so nothing is queued
+	// behind it
+	stats := rl.snapshot()[KVClassRead]
+	if stats.Requests != 1 || stats.Throttled != 0 {
+		t.Fatalf("stats = %+v, want 1 request and no throttling", stats)
+	}
+}
+
+func TestKVRateLimiterStats(t *testing.T) {
+	rl := newKVRateLimiter(&KVRateLimitOptions{List: &KVRateLimit{RequestsPerSecond: 50}})
+
+	for i := 0; i < 3; i++ {
+		if err := rl.wait(context.Background(), KVClassList); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each throttled request waits for at most one 20ms interval, less when
+	// the caller was slow to come back
+	stats := rl.snapshot()[KVClassList]
+	if stats.Requests != 3 || stats.Throttled != 2 || stats.ThrottledTime <= 0 || stats.ThrottledTime > 40*time.Millisecond {
+		t.Fatalf("stats = %+v, want 3 requests, 2 throttled for up to 40ms", stats)
+	}
+}
+
+func TestKVRateLimitThrottlesRetries(t *testing.T) {
+	client, kv := newTestClient(t, &ClientOptions{
+		KVRateLimit: &KVRateLimitOptions{Read: &KVRateLimit{RequestsPerSecond: 1000, Burst: 10}},
+	})
+	if client.retry == nil {
+		t.Fatal("built-in retries are used with rate limiting")
+	}
+	client.retry.InitialBackoff = time.Millisecond
+
+	kv.put("item:1", []byte("v"))
+	failures := 2
+	kv.fail = func(*http.Request) int {
+		if failures > 0 {
+			failures--
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+
+	if _, err := client.KVGet(context.Background(), "item:1"); err != nil {
+		t.Fatalf("KVGet: %v", err)
+	}
+	if stats := client.KVRateLimitStats()[KVClassRead]; stats.Requests != 3 {
+		t.Fatalf("%d requests passed the limiter, want 3 including the retries", stats.Requests)
+	}
+}
@@ -0,0 +1,185 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Log attribute keys shared by all SDK log records.
+const (
+	logKeyOp        = "op"
+	logKeyUserID    = "user_id"
+	logKeyDuration  = "duration"
+	logKeyErrorCode = "error_code"
+	logKeyError     = "error"
+	logKeyOutcome   = "outcome"
+	logKeyKVClass   = "kv.class"
+)
+
+// redacted replaces the value of sensitive log attributes.
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are attribute keys whose values are never logged. Keys
+// ending in one of them after an underscore or dot, such as "new_password"
+// or "user.password_hash", are redacted as well.
+var sensitiveLogKeys = []string{
+	"password",
+	"password_hash",
+	"passwordhash",
+	"hash",
+	"token",
+	"secret",
+	"authorization",
+	"api_key",
+}
+
+// newLogger returns the SDK logger writing to l through a redacting
+// handler, or a logger that discards everything if l is nil
+func newLogger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(discardHandler{})
+	}
+	return slog.New(&redactHandler{handler: l.Handler()})
+}
+
+// logAuth logs the result of an auth operation. Successes are logged at
+// level, client errors at Warn and storage or internal failures at Error.
+func (c *Client) logAuth(ctx context.Context, level slog.Level, op, userID string, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String(logKeyOp, op),
+		slog.Duration(logKeyDuration, time.Since(start)),
+	}
+	if userID != "" {
+		attrs = append(attrs, slog.String(logKeyUserID, userID))
+	}
+
+	msg := op + " succeeded"
+	if err != nil {
+		msg = op + " failed"
+		level = slog.LevelWarn
+		code := 500
+		message := "internal error"
+		var appErr *AppError
+		if errors.As(err, &appErr) {
+			code = appErr.Code
+			message = appErr.Message
+		}
+		if code >= 500 {
+			level = slog.LevelError
+		}
+		attrs = append(attrs,
+			slog.Int(logKeyErrorCode, code),
+			slog.String(logKeyError, message),
+			slog.String(logKeyOutcome, authOutcome(err)),
+		)
+	}
+
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logKV logs a KV request: failures other than a missing key at Warn, and
+// every request at Debug
+func (c *Client) logKV(ctx context.Context, name string, class KVOperationClass, key slog.Attr, start time.Time, err error) {
+	level := slog.LevelDebug
+	if err != nil && !isKVNotFound(err) {
+		level = slog.LevelWarn
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	c.logger.LogAttrs(ctx, level, "KV request",
+		slog.String(logKeyOp, name),
+		slog.String(logKeyKVClass, class.String()),
+		key,
+		slog.Duration(logKeyDuration, time.Since(start)),
+		slog.String(logKeyOutcome, kvOutcome(err)),
+	)
+}
+
+// LogValue implements slog.LogValuer, leaving out the password hash.
+func (u *User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", u.ID),
+		slog.String("email", u.Email),
+	)
+}
+
+// LogValue implements slog.LogValuer, leaving out the token.
+func (r *LoginResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Time("expires_at", r.ExpiresAt),
+		slog.String("user_id", r.User.ID),
+	)
+}
+
+// redactHandler replaces the values of sensitive attributes before
+// passing records to the wrapped handler.
+type redactHandler struct {
+	handler slog.Handler
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	safe := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		safe[i] = redactAttr(a)
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(safe)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{handler: h.handler.WithGroup(name)}
+}
+
+// redactAttr redacts a sensitive attribute, descending into groups
+func redactAttr(a slog.Attr) slog.Attr {
+	if isSensitiveLogKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return a
+	}
+
+	group := a.Value.Group()
+	safe := make([]slog.Attr, len(group))
+	for i, g := range group {
+		safe[i] = redactAttr(g)
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(safe...)}
+}
+
+func isSensitiveLogKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveLogKeys {
+		if key == s || strings.HasSuffix(key, "_"+s) || strings.HasSuffix(key, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// discardHandler drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,159 @@
+package cloudflare_auth_sdk
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedactingLogger returns an SDK logger writing JSON records to buf
+func newRedactingLogger(buf *bytes.Buffer) *slog.Logger {
+	return newLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+}
+
+func TestIsSensitiveLogKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"Password", true},
+		{"new_password", true},
+		{"user.password_hash", true},
+		{"refresh_token", true},
+		{"client_secret", true},
+		{"Authorization", true},
+		{"service.api_key", true},
+		{"passwordless", false},
+		{"tokens_issued", false},
+		{"secretary", false},
+		{"user_id", false},
+		{"email", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSensitiveLogKey(tt.key); got != tt.want {
+			t.Errorf("isSensitiveLogKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	const secret = "s3cr3t-value"
+
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+	}{
+		{
+			name: "top-level attribute",
+			log:  func(l *slog.Logger) { l.Info("msg", "password", secret) },
+		},
+		{
+			name: "suffix-matched key",
+			log:  func(l *slog.Logger) { l.Info("msg", "refresh_token", secret) },
+		},
+		{
+			name: "nested groups",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("request", slog.Group("auth", slog.String("client_secret", secret))))
+			},
+		},
+		{
+			name: "WithAttrs",
+			log:  func(l *slog.Logger) { l.With("authorization", "Bearer "+secret).Info("msg") },
+		},
+		{
+			name: "WithGroup",
+			log:  func(l *slog.Logger) { l.WithGroup("user").Info("msg", "password_hash", secret) },
+		},
+		{
+			name: "LogValuer resolving to a group",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "credentials", credentialsValuer{token: secret})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(newRedactingLogger(&buf))
+
+			out := buf.String()
+			if strings.Contains(out, secret) {
+				t.Fatalf("secret logged: %s", out)
+			}
+			if !strings.Contains(out, redacted) {
+				t.Fatalf("no redacted attribute in %s", out)
+			}
+		})
+	}
+}
+
+func TestRedactHandlerKeepsOtherAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	newRedactingLogger(&buf).Info("msg", "user_id", "u1", slog.Group("kv", slog.String("key", "user:id:u1")))
+
+	out := buf.String()
+	if !strings.Contains(out, `"user_id":"u1"`) || !strings.Contains(out, `"kv":{"key":"user:id:u1"}`) {
+		t.Fatalf("attributes missing from %s", out)
+	}
+}
+
+func TestLogValueLeavesOutSecrets(t *testing.T) {
+	user := &User{ID: "u1", Email: "alice@example.com", PasswordHash: "$2a$10$hash"}
+	resp := &LoginResponse{Token: "jwt-token", ExpiresAt: time.Now(), User: UserInfo{ID: user.ID, Email: user.Email}}
+
+	// Through a plain handler, so only LogValue keeps the secrets out
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("msg", "user", user, "login", resp)
+
+	out := buf.String()
+	for _, secret := range []string{user.PasswordHash, resp.Token} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q logged: %s", secret, out)
+		}
+	}
+	for _, want := range []string{`"id":"u1"`, `"email":"alice@example.com"`, `"user_id":"u1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s missing from %s", want, out)
+		}
+	}
+}
+
+func TestLoginDoesNotLogSecrets(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	client, _ := newTestClient(t, &ClientOptions{
+		Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+
+	if _, err := client.Register(ctx, "alice@example.com", "correct-horse"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Login(ctx, "alice@example.com", "correct-horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.logger.Info("issued", "response", resp, "token", resp.Token)
+
+	out := buf.String()
+	for _, secret := range []string{"correct-horse", resp.Token} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("%q logged", secret)
+		}
+	}
+}
+
+// credentialsValuer resolves to a group holding a token
+type credentialsValuer struct {
+	token string
+}
+
+func (c credentialsValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("access_token", c.token))
+}
@@ -0,0 +1,138 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go/v6"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Outcome labels passed to a MetricsRecorder. They have a fixed, small set
+// of values so they can be used as metric labels.
+const (
+	OutcomeSuccess            = "success"
+	OutcomeInvalidInput       = "invalid_input"
+	OutcomeUserNotFound       = "user_not_found"
+	OutcomeUserAlreadyExists  = "user_already_exists"
+	OutcomeInvalidCredentials = "invalid_credentials"
+	OutcomeInvalidToken       = "invalid_token"
+	OutcomeTokenExpired       = "token_expired"
+	OutcomeSessionExpired     = "session_expired"
+	OutcomeRateLimited        = "rate_limited"
+	OutcomeStorageError       = "storage_error"
+	OutcomeRejected           = "rejected" // Rejected by a hook
+	OutcomeError              = "error"
+
+	// KV request outcomes
+	OutcomeNotFound    = "not_found"
+	OutcomeThrottled   = "throttled"    // Cloudflare answered 429, or the client-side limit
+	OutcomeServerError = "server_error" // Cloudflare answered 5xx
+	OutcomeClientError = "client_error" // Cloudflare answered another 4xx
+	OutcomeUnavailable = "unavailable"  // Rejected by the open circuit breaker
+	OutcomeTimeout     = "timeout"
+)
+
+// MetricsRecorder receives metrics from the SDK.
+//
+// Implementations must be safe for concurrent use and should not block.
+// The prommetrics and otelmetrics packages provide implementations for
+// Prometheus and OpenTelemetry.
+type MetricsRecorder interface {
+	// RecordLogin counts a Login call by outcome.
+	RecordLogin(ctx context.Context, outcome string)
+
+	// RecordRegistration counts a Register call by outcome.
+	RecordRegistration(ctx context.Context, outcome string)
+
+	// RecordTokenValidation counts a token validation by outcome.
+	RecordTokenValidation(ctx context.Context, outcome string)
+
+	// RecordClientCredentials counts an ExchangeClientCredentials call by
+	// outcome.
+	RecordClientCredentials(ctx context.Context, outcome string)
+
+	// RecordKVRequest records a Cloudflare KV request, including retries
+	// and rate limit waits, by operation (e.g. "KV.Get") and outcome.
+	RecordKVRequest(ctx context.Context, operation, outcome string, duration time.Duration)
+}
+
+// nopMetrics is the MetricsRecorder used when none is configured.
+type nopMetrics struct{}
+
+func (nopMetrics) RecordLogin(context.Context, string)             {}
+func (nopMetrics) RecordRegistration(context.Context, string)      {}
+func (nopMetrics) RecordTokenValidation(context.Context, string)   {}
+func (nopMetrics) RecordClientCredentials(context.Context, string) {}
+
+func (nopMetrics) RecordKVRequest(context.Context, string, string, time.Duration) {}
+
+// authOutcome returns the outcome label of an auth flow result
+func authOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrInvalidInput):
+		return OutcomeInvalidInput
+	case errors.Is(err, ErrUserNotFound):
+		return OutcomeUserNotFound
+	case errors.Is(err, ErrUserAlreadyExists):
+		return OutcomeUserAlreadyExists
+	case errors.Is(err, ErrInvalidCredentials):
+		return OutcomeInvalidCredentials
+	case errors.Is(err, ErrRateLimited):
+		return OutcomeRateLimited
+	case IsSessionExpired(err):
+		return OutcomeSessionExpired
+	case errors.Is(err, ErrTokenExpired), errors.Is(err, jwt.ErrTokenExpired):
+		return OutcomeTokenExpired
+	case errors.Is(err, ErrKVOperationFailed):
+		return OutcomeStorageError
+	}
+
+	// parseToken wraps the jwt parse errors in a 401 AppError, hookError
+	// wraps other errors in a 403 one
+	var appErr *AppError
+	if errors.Is(err, ErrInvalidToken) || errors.As(err, &appErr) && appErr.Code == http.StatusUnauthorized {
+		return OutcomeInvalidToken
+	}
+	if appErr != nil && appErr.Code == http.StatusForbidden {
+		return OutcomeRejected
+	}
+	return OutcomeError
+}
+
+// kvOutcome returns the outcome label of a KV request result
+func kvOutcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	if isKVNotFound(err) {
+		return OutcomeNotFound
+	}
+	if errors.Is(err, ErrKVUnavailable) {
+		return OutcomeUnavailable
+	}
+	if errors.Is(err, errKVThrottled) {
+		return OutcomeThrottled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return OutcomeTimeout
+	}
+
+	var apiErr *cloudflare.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return OutcomeThrottled
+		case apiErr.StatusCode >= 500:
+			return OutcomeServerError
+		default:
+			return OutcomeClientError
+		}
+	}
+
+	return OutcomeError
+}
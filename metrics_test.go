@@ -0,0 +1,80 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testContextKey struct{}
+
+// recordingMetrics records the token validations it receives and the
+// testContextKey value of their contexts, and client credentials exchanges
+type recordingMetrics struct {
+	nopMetrics
+
+	mu                sync.Mutex
+	validations       []string
+	contexts          []interface{}
+	clientCredentials []string
+}
+
+func (m *recordingMetrics) RecordClientCredentials(ctx context.Context, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clientCredentials = append(m.clientCredentials, outcome)
+}
+
+func (m *recordingMetrics) RecordTokenValidation(ctx context.Context, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.validations = append(m.validations, outcome)
+	m.contexts = append(m.contexts, ctx.Value(testContextKey{}))
+}
+
+func TestVerifyTokenRecordsWithCallerContext(t *testing.T) {
+	metrics := &recordingMetrics{}
+	client, _ := newTestClient(t, &ClientOptions{Metrics: metrics})
+
+	token, err := client.issueToken(&User{ID: "u1", Email: "alice@example.com"}, "", nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "direct")
+	if _, err := client.VerifyTokenContext(ctx, token.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := client.Middleware(&MiddlewareOptions{ClaimsOnly: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), testContextKey{}, "middleware"))
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	if _, err := client.VerifyToken("not-a-token"); err == nil {
+		t.Fatal("VerifyToken accepted an invalid token")
+	}
+
+	want := []interface{}{"direct", "middleware", nil}
+	if len(metrics.contexts) != len(want) {
+		t.Fatalf("recorded %d validations, want %d", len(metrics.contexts), len(want))
+	}
+	for i := range want {
+		if metrics.contexts[i] != want[i] {
+			t.Errorf("validation %d recorded with context value %v, want %v", i, metrics.contexts[i], want[i])
+		}
+	}
+	if metrics.validations[0] != OutcomeSuccess || metrics.validations[2] != OutcomeInvalidToken {
+		t.Errorf("outcomes %v", metrics.validations)
+	}
+}
@@ -0,0 +1,312 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TokenSource identifies where the middleware looks for a token.
+type TokenSource int
+
+const (
+	// TokenFromHeader reads the token from the "Authorization: Bearer" header.
+	TokenFromHeader TokenSource = iota
+	// TokenFromCookie reads the token from a cookie.
+	TokenFromCookie
+	// TokenFromQuery reads the token from a URL query parameter.
+	TokenFromQuery
+)
+
+// MiddlewareOptions contains options for Client.Middleware.
+type MiddlewareOptions struct {
+	Sources    []TokenSource // Token sources tried in order (default: header only)
+	CookieName string        // Cookie holding the token (default: "token")
+	QueryParam string        // Query parameter holding the token (default: "token")
+
+	// Optional lets requests without a token through unauthenticated.
+	// Requests carrying an invalid token are still rejected.
+	Optional bool
+
+	// ClaimsOnly verifies tokens with VerifyToken instead of ValidateToken,
+	// avoiding KV reads. Only the claims are stored in the context.
+	ClaimsOnly bool
+
+	// FallbackToClaims verifies tokens with VerifyToken while the KV circuit
+	// breaker is open, instead of rejecting requests with 503. Only the
+	// claims are stored in the context for such requests.
+	FallbackToClaims bool
+
+	// APIKeys accepts API keys as an alternative to tokens, read from the
+	// APIKeyHeader header or from an "Authorization: Bearer ak_..." header.
+	// The key's owner, a user or a service account, is stored in the
+	// context together with the key, and no claims are stored.
+	APIKeys      bool
+	APIKeyHeader string // Header holding an API key (default: "X-API-Key")
+
+	// ClientIP returns the client IP address Client.Handler passes to Login
+	// and Register for rate limiting (default: RateLimitByIP). Behind a
+	// proxy, read the proxy's client IP header instead.
+	ClientIP func(*http.Request) string
+}
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	claimsContextKey
+	apiKeyContextKey
+	serviceAccountContextKey
+	clientIPContextKey
+)
+
+// Middleware returns net/http middleware that authenticates requests with
+// ValidateToken and stores the user and claims in the request context.
+// For service account tokens the account is stored instead of the user,
+// as retrieved by ServiceAccountFromContext.
+//
+// Failures are written as a JSON ErrorResponse using AppError.Code as the
+// status code.
+//
+// Example:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/me", client.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//	    user, _ := sdk.UserFromContext(r.Context())
+//	    fmt.Fprintln(w, user.Email)
+//	})))
+func (c *Client) Middleware(opts *MiddlewareOptions) func(http.Handler) http.Handler {
+	return c.middleware(opts, WriteError)
+}
+
+// middleware implements Middleware, writing failures with writeError
+func (c *Client) middleware(opts *MiddlewareOptions, writeError func(http.ResponseWriter, error)) func(http.Handler) http.Handler {
+	const op = "Client.Middleware"
+
+	if opts == nil {
+		opts = &MiddlewareOptions{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := opts.extractAPIKey(r); key != "" {
+				ctx, err := c.apiKeyContext(r.Context(), key)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			token := opts.extractToken(r)
+			if token == "" {
+				if opts.Optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+				writeError(w, NewAppError(op, ErrInvalidToken, "missing authentication token", 401))
+				return
+			}
+
+			ctx, err := c.Authenticate(r.Context(), token, &AuthenticateOptions{
+				ClaimsOnly:       opts.ClaimsOnly,
+				FallbackToClaims: opts.FallbackToClaims,
+			})
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AuthenticateOptions contains options for Client.Authenticate.
+type AuthenticateOptions struct {
+	// ClaimsOnly verifies tokens with VerifySession instead of
+	// ValidateSession, avoiding KV reads. Only the claims are stored in
+	// the context.
+	ClaimsOnly bool
+
+	// FallbackToClaims verifies tokens with VerifySession while the KV
+	// circuit breaker is open, instead of failing with 503.
+	FallbackToClaims bool
+
+	// APIKeys accepts API keys, recognized by their "ak_" prefix, as an
+	// alternative to tokens.
+	APIKeys bool
+}
+
+// Authenticate checks a bearer credential, a token or, with opts.APIKeys,
+// an API key, and returns a copy of ctx carrying the authenticated
+// principal: the user or service account and the claims for tokens, the
+// key and its owner for API keys. opts may be nil.
+//
+// Client.Middleware and the grpcauth interceptors both use it, so the
+// transports accept the same credentials.
+func (c *Client) Authenticate(ctx context.Context, credential string, opts *AuthenticateOptions) (context.Context, error) {
+	if opts == nil {
+		opts = &AuthenticateOptions{}
+	}
+
+	if opts.APIKeys && strings.HasPrefix(credential, apiKeyPrefix) {
+		return c.apiKeyContext(ctx, credential)
+	}
+
+	var info *SessionInfo
+	var err error
+	if opts.ClaimsOnly {
+		info, err = c.VerifySession(ctx, credential)
+	} else {
+		info, err = c.ValidateSession(ctx, credential)
+		if err != nil && opts.FallbackToClaims && IsKVUnavailable(err) {
+			info, err = c.VerifySession(ctx, credential)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = NewContext(ctx, info.User, info.Claims)
+	if info.ServiceAccount != nil {
+		ctx = NewServiceAccountContext(ctx, info.ServiceAccount)
+	}
+	return ctx, nil
+}
+
+// apiKeyContext validates an API key and returns a copy of ctx carrying the
+// key and its owner; no claims are stored
+func (c *Client) apiKeyContext(ctx context.Context, key string) (context.Context, error) {
+	info, err := c.ValidateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(NewContext(ctx, info.User, nil), apiKeyContextKey, info.APIKey)
+	if info.ServiceAccount != nil {
+		ctx = NewServiceAccountContext(ctx, info.ServiceAccount)
+	}
+	return ctx, nil
+}
+
+// NewContext returns a copy of ctx carrying the authenticated user and
+// claims, as retrieved by UserFromContext and ClaimsFromContext.
+//
+// The HTTP middleware and the grpcauth interceptors use it to share the
+// same accessors.
+func NewContext(ctx context.Context, user *User, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, user)
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// UserFromContext returns the authenticated user stored by the middleware.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userContextKey).(*User)
+	return user, ok && user != nil
+}
+
+// ClaimsFromContext returns the token claims stored by the middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// NewServiceAccountContext returns a copy of ctx carrying the authenticated
+// service account, as retrieved by ServiceAccountFromContext.
+func NewServiceAccountContext(ctx context.Context, account *ServiceAccount) context.Context {
+	return context.WithValue(ctx, serviceAccountContextKey, account)
+}
+
+// ServiceAccountFromContext returns the authenticated service account
+// stored by the middleware, if the request was made by one.
+func ServiceAccountFromContext(ctx context.Context) (*ServiceAccount, bool) {
+	account, ok := ctx.Value(serviceAccountContextKey).(*ServiceAccount)
+	return account, ok && account != nil
+}
+
+// NewClientIPContext returns a copy of ctx carrying the IP address of the
+// client making the request, as retrieved by ClientIPFromContext.
+//
+// Login and Register use it for LoginRateLimit and RegisterRateLimit, and
+// do not throttle calls without it; Client.Handler stores the address of
+// each request.
+func NewClientIPContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey, ip)
+}
+
+// ClientIPFromContext returns the client IP address stored by
+// NewClientIPContext.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPContextKey).(string)
+	return ip, ok && ip != ""
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, if
+// the middleware accepted an API key.
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*APIKey)
+	return key, ok && key != nil
+}
+
+// extractAPIKey returns the API key of the request, if API keys are enabled
+func (o *MiddlewareOptions) extractAPIKey(r *http.Request) string {
+	if !o.APIKeys {
+		return ""
+	}
+
+	header := o.APIKeyHeader
+	if header == "" {
+		header = "X-API-Key"
+	}
+	if key := strings.TrimSpace(r.Header.Get(header)); key != "" {
+		return key
+	}
+	if token := bearerToken(r.Header.Get("Authorization")); strings.HasPrefix(token, apiKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// extractToken returns the first token found in the configured sources
+func (o *MiddlewareOptions) extractToken(r *http.Request) string {
+	sources := o.Sources
+	if len(sources) == 0 {
+		sources = []TokenSource{TokenFromHeader}
+	}
+
+	for _, source := range sources {
+		switch source {
+		case TokenFromHeader:
+			if token := bearerToken(r.Header.Get("Authorization")); token != "" {
+				return token
+			}
+		case TokenFromCookie:
+			cookieName := o.CookieName
+			if cookieName == "" {
+				cookieName = "token"
+			}
+			if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
+				return cookie.Value
+			}
+		case TokenFromQuery:
+			queryParam := o.QueryParam
+			if queryParam == "" {
+				queryParam = "token"
+			}
+			if token := r.URL.Query().Get(queryParam); token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header value
+func bearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,85 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareTokenSources(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, nil)
+
+	// Each source carries the token of a different user, so the
+	// authenticated user tells which source won
+	tokens := make(map[string]string)
+	for _, email := range []string{"header@example.com", "cookie@example.com", "query@example.com"} {
+		if _, err := client.Register(ctx, email, "password"); err != nil {
+			t.Fatal(err)
+		}
+		login, err := client.Login(ctx, email, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[email] = login.Token
+	}
+	all := []TokenSource{TokenFromHeader, TokenFromCookie, TokenFromQuery}
+
+	tests := []struct {
+		name       string
+		opts       MiddlewareOptions
+		header     bool // Authorization header with the header user's token
+		cookie     string
+		query      string
+		wantStatus int
+		want       string // authenticated user's email
+	}{
+		{name: "header by default", header: true, wantStatus: http.StatusOK, want: "header@example.com"},
+		{name: "cookie", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromCookie}}, cookie: "token", wantStatus: http.StatusOK, want: "cookie@example.com"},
+		{name: "custom cookie name", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromCookie}, CookieName: "session"}, cookie: "session", wantStatus: http.StatusOK, want: "cookie@example.com"},
+		{name: "other cookie name", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromCookie}, CookieName: "session"}, cookie: "token", wantStatus: http.StatusUnauthorized},
+		{name: "query", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromQuery}}, query: "token", wantStatus: http.StatusOK, want: "query@example.com"},
+		{name: "custom query parameter", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromQuery}, QueryParam: "access_token"}, query: "access_token", wantStatus: http.StatusOK, want: "query@example.com"},
+		{name: "other query parameter", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromQuery}, QueryParam: "access_token"}, query: "token", wantStatus: http.StatusUnauthorized},
+		{name: "first source wins", opts: MiddlewareOptions{Sources: all}, header: true, cookie: "token", query: "token", wantStatus: http.StatusOK, want: "header@example.com"},
+		{name: "order is respected", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromQuery, TokenFromCookie, TokenFromHeader}}, header: true, cookie: "token", query: "token", wantStatus: http.StatusOK, want: "query@example.com"},
+		{name: "later source when earlier ones are empty", opts: MiddlewareOptions{Sources: all}, query: "token", wantStatus: http.StatusOK, want: "query@example.com"},
+		{name: "cookie ignored by default", cookie: "token", wantStatus: http.StatusUnauthorized},
+		{name: "query ignored by default", query: "token", wantStatus: http.StatusUnauthorized},
+		{name: "header ignored when not configured", opts: MiddlewareOptions{Sources: []TokenSource{TokenFromCookie, TokenFromQuery}}, header: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			var got string
+			handler := client.Middleware(&opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if user, ok := UserFromContext(r.Context()); ok {
+					got = user.Email
+				}
+			}))
+
+			target := "/"
+			if tt.query != "" {
+				target += "?" + tt.query + "=" + tokens["query@example.com"]
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.header {
+				req.Header.Set("Authorization", "Bearer "+tokens["header@example.com"])
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: tt.cookie, Value: tokens["cookie@example.com"]})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.want {
+				t.Fatalf("authenticated as %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package cloudflare_auth_sdk
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPIPath is the path at which Client.Handler serves the OpenAPI document.
+const OpenAPIPath = "/openapi.json"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI 3 document describing the API served by
+// Client.Handler.
+func OpenAPISpec() []byte {
+	spec := make([]byte, len(openAPISpec))
+	copy(spec, openAPISpec)
+	return spec
+}
+
+// serveOpenAPI writes the embedded OpenAPI document
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
@@ -0,0 +1,327 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAPIDoc is the part of the OpenAPI document the conformance test uses
+type openAPIDoc struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Responses map[string]openAPIResponse `json:"responses"`
+		Schemas   map[string]*openAPISchema  `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Security  []map[string][]string      `json:"security"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *openAPISchema `json:"schema"`
+	} `json:"content"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Format               string                    `json:"format"`
+	Required             []string                  `json:"required"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	AdditionalProperties *bool                     `json:"additionalProperties"`
+}
+
+func loadOpenAPIDoc(t *testing.T) *openAPIDoc {
+	t.Helper()
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(OpenAPISpec(), &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return &doc
+}
+
+// response returns the declared response of an operation, resolving refs
+func (d *openAPIDoc) response(method, path string, status int) (openAPIResponse, bool) {
+	op, ok := d.Paths[path][strings.ToLower(method)]
+	if !ok {
+		return openAPIResponse{}, false
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return openAPIResponse{}, false
+	}
+	if name, found := strings.CutPrefix(resp.Ref, "#/components/responses/"); found {
+		resp = d.Components.Responses[name]
+	}
+	return resp, true
+}
+
+// validate checks a decoded JSON value against a schema
+func (d *openAPIDoc) validate(schema *openAPISchema, value interface{}, path string) error {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		resolved, found := d.Components.Schemas[name]
+		if !found {
+			return fmt.Errorf("%s: unknown schema %q", path, name)
+		}
+		return d.validate(resolved, value, path)
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", path, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", path, value)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: invalid date-time %q", path, s)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %v", path, value)
+		}
+	}
+	return nil
+}
+
+// TestHandlerConformsToOpenAPI runs every route of Client.Handler and checks
+// that each status code is declared in openapi.json and each body matches
+// the declared schema.
+func TestHandlerConformsToOpenAPI(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	client, _ := newTestClient(t, nil)
+	server := httptest.NewServer(client.Handler(nil))
+	defer server.Close()
+
+	const (
+		email    = "alice@example.com"
+		blocked  = "mallory@example.com"
+		password = "correct horse battery staple"
+	)
+
+	// Hooks reject the blocked address, and every token while rejectTokens is set
+	rejectTokens := false
+	client.BeforeRegister(func(ctx context.Context, email string) error {
+		if email == "blocked@example.com" {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+	client.BeforeLogin(func(ctx context.Context, user *User, claims *Claims) error {
+		if user.Email == blocked {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+	client.OnTokenValidated(func(ctx context.Context, info *SessionInfo) error {
+		if rejectTokens {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+	credentials := func(email, password string) string {
+		data, _ := json.Marshal(CredentialsRequest{Email: email, Password: password})
+		return string(data)
+	}
+
+	// covered records the operations and statuses the test observed
+	covered := make(map[string]bool)
+
+	call := func(method, path, token, body string, wantStatus int) map[string]interface{} {
+		t.Helper()
+
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req, err := http.NewRequest(method, server.URL+path, reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s: status %d, want %d", method, path, resp.StatusCode, wantStatus)
+		}
+		declared, ok := doc.response(method, path, resp.StatusCode)
+		if !ok {
+			t.Fatalf("%s %s: status %d is not declared in openapi.json", method, path, resp.StatusCode)
+		}
+		covered[method+" "+path] = true
+		covered[method+" "+path+" "+strconv.Itoa(resp.StatusCode)] = true
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, hasContent := declared.Content["application/json"]
+		if !hasContent {
+			if len(data) != 0 {
+				t.Fatalf("%s %s: status %d declares no body, got %q", method, path, resp.StatusCode, data)
+			}
+			return nil
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Fatalf("%s %s: Content-Type %q, want application/json", method, path, ct)
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			t.Fatalf("%s %s: invalid JSON body: %v", method, path, err)
+		}
+		if err := doc.validate(content.Schema, value, "body"); err != nil {
+			t.Fatalf("%s %s: status %d: %v", method, path, resp.StatusCode, err)
+		}
+		obj, _ := value.(map[string]interface{})
+		return obj
+	}
+
+	call("POST", "/auth/register", "", credentials(email, password), http.StatusCreated)
+	call("POST", "/auth/register", "", credentials(email, password), http.StatusConflict)
+	call("POST", "/auth/register", "", `{"email":"bob@example.com","password":"x","role":"admin"}`, http.StatusBadRequest)
+	call("POST", "/auth/register", "", credentials("blocked@example.com", password), http.StatusForbidden)
+	call("POST", "/auth/register", "", credentials(blocked, password), http.StatusCreated)
+
+	login := call("POST", "/auth/login", "", credentials(email, password), http.StatusOK)
+	token, _ := login["token"].(string)
+	call("POST", "/auth/login", "", credentials(email, "wrong password"), http.StatusUnauthorized)
+	call("POST", "/auth/login", "", credentials("nobody@example.com", password), http.StatusUnauthorized)
+	call("POST", "/auth/login", "", `{"email":""}`, http.StatusBadRequest)
+	call("POST", "/auth/login", "", credentials(blocked, password), http.StatusForbidden)
+
+	refreshed := call("POST", "/auth/refresh", token, "", http.StatusOK)
+	call("POST", "/auth/refresh", "", "", http.StatusUnauthorized)
+	call("POST", "/auth/refresh", "not-a-token", "", http.StatusUnauthorized)
+	rejectTokens = true
+	call("POST", "/auth/refresh", token, "", http.StatusForbidden)
+	rejectTokens = false
+
+	me := call("GET", "/auth/me", token, "", http.StatusOK)
+	if me["email"] != email {
+		t.Fatalf("GET /auth/me: email %v, want %s", me["email"], email)
+	}
+	call("GET", "/auth/me", "", "", http.StatusUnauthorized)
+
+	call("POST", "/auth/logout", refreshed["token"].(string), "", http.StatusNoContent)
+	call("POST", "/auth/logout", "", "", http.StatusUnauthorized)
+
+	// Service accounts have no user profile
+	account, err := client.CreateServiceAccount(context.Background(), &ServiceAccountOptions{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := client.ExchangeClientCredentials(context.Background(), account.ID, account.ClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call("GET", "/auth/me", service.Token, "", http.StatusForbidden)
+	call("POST", "/auth/refresh", service.Token, "", http.StatusBadRequest)
+	call("DELETE", "/auth/me", service.Token, "", http.StatusForbidden)
+
+	call("DELETE", "/auth/me", "", "", http.StatusUnauthorized)
+	call("DELETE", "/auth/me", token, "", http.StatusNoContent)
+	call("GET", "/auth/me", token, "", http.StatusUnauthorized)
+	call("DELETE", "/auth/me", token, "", http.StatusUnauthorized)
+	call("POST", "/auth/refresh", token, "", http.StatusUnauthorized)
+
+	call("GET", OpenAPIPath, "", "", http.StatusOK)
+
+	// Every operation and every declared success status must be exercised
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			key := strings.ToUpper(method) + " " + path
+			if !covered[key] {
+				t.Errorf("%s is declared in openapi.json but not tested", key)
+			}
+			for status := range op.Responses {
+				if strings.HasPrefix(status, "2") && !covered[key+" "+status] {
+					t.Errorf("%s: success status %s is declared but not returned", key, status)
+				}
+			}
+		}
+	}
+}
+
+// TestOpenAPIRoutesAreServed checks that the document declares exactly the
+// routes Client.Handler serves.
+func TestOpenAPIRoutesAreServed(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	client, _ := newTestClient(t, nil)
+	handler := client.Handler(nil)
+
+	served := map[string]bool{
+		"POST /auth/register": true,
+		"POST /auth/login":    true,
+		"POST /auth/refresh":  true,
+		"POST /auth/logout":   true,
+		"GET /auth/me":        true,
+		"DELETE /auth/me":     true,
+		"GET " + OpenAPIPath:  true,
+	}
+
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			key := strings.ToUpper(method) + " " + path
+			if !served[key] {
+				t.Errorf("openapi.json declares %s, which Client.Handler does not serve", key)
+			}
+			delete(served, key)
+		}
+	}
+	for key := range served {
+		t.Errorf("Client.Handler serves %s, which openapi.json does not declare", key)
+	}
+
+	// Undeclared methods are rejected by the router
+	req := httptest.NewRequest(http.MethodPut, "/auth/login", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /auth/login: status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -1,6 +1,12 @@
 package cloudflare_auth_sdk
 
-import "errors"
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 // ClientOptions contains the configuration for creating a new SDK client.
 type ClientOptions struct {
@@ -16,6 +22,55 @@ type ClientOptions struct {
 	// JWT configuration
 	JWTSecret          string // Secret key for signing JWT tokens
 	JWTExpirationHours int    // Token expiration in hours (default: 24)
+
+	// ServiceTokenTTL is the lifetime of tokens issued to service accounts
+	// by ExchangeClientCredentials (default: 15 minutes)
+	ServiceTokenTTL time.Duration
+
+	// Session configuration
+	SessionIdleTimeout   time.Duration // Idle timeout for sliding sessions (default: disabled)
+	SessionTouchInterval time.Duration // Minimum interval between session activity writes (default: 1 minute)
+
+	// User cache for ValidateToken lookups
+	UserCacheSize int           // Maximum number of cached users (default: disabled)
+	UserCacheTTL  time.Duration // Lifetime of cached users (default: 1 minute)
+
+	// KVCache enables a local read-through cache for KV reads (default: disabled)
+	KVCache *KVCacheOptions
+
+	// Retry replaces the Cloudflare client's built-in retries with a
+	// configurable policy (default: built-in retries, up to 10, or the
+	// default RetryPolicy when KVRateLimit is set)
+	Retry *RetryPolicy
+
+	// CircuitBreaker fails KV requests fast while Cloudflare is failing (default: disabled)
+	CircuitBreaker *CircuitBreakerOptions
+
+	// KVRateLimit limits the rate of KV requests per operation class (default: disabled)
+	KVRateLimit *KVRateLimitOptions
+
+	// LoginRateLimit limits failed login attempts per email address and
+	// client IP, as stored by NewClientIPContext. Attempts without a client
+	// IP are not throttled (default: disabled)
+	LoginRateLimit *RateLimitOptions
+
+	// RegisterRateLimit limits registrations per client IP, as stored by
+	// NewClientIPContext. Registrations without a client IP are not
+	// throttled (default: disabled)
+	RegisterRateLimit *RateLimitOptions
+
+	// TracerProvider receives OpenTelemetry spans for auth flows and KV
+	// requests (default: no tracing)
+	TracerProvider trace.TracerProvider
+
+	// Metrics receives counters and latencies of auth flows and KV
+	// requests (default: no metrics)
+	Metrics MetricsRecorder
+
+	// Logger receives auth events and storage failures, and KV request
+	// details at debug level. Passwords, tokens and hashes are redacted
+	// (default: no logging)
+	Logger *slog.Logger
 }
 
 // Validate checks if all required options are set and valid.
@@ -37,6 +92,54 @@ func (o *ClientOptions) Validate() error {
 		return errors.New("either APIToken or both APIKey and Email are required")
 	}
 
+	if o.ServiceTokenTTL < 0 {
+		return errors.New("ServiceTokenTTL must not be negative")
+	}
+
+	if o.SessionIdleTimeout < 0 || o.SessionTouchInterval < 0 {
+		return errors.New("session durations must not be negative")
+	}
+
+	if o.SessionIdleTimeout > 0 && o.SessionTouchInterval >= o.SessionIdleTimeout {
+		return errors.New("SessionTouchInterval must be shorter than SessionIdleTimeout")
+	}
+
+	if o.UserCacheSize < 0 || o.UserCacheTTL < 0 {
+		return errors.New("user cache size and TTL must not be negative")
+	}
+
+	if o.KVCache != nil && (o.KVCache.MaxBytes < 0 || o.KVCache.TTL < 0 || o.KVCache.NegativeTTL < 0) {
+		return errors.New("KV cache size and TTLs must not be negative")
+	}
+
+	if o.Retry != nil && (o.Retry.MaxAttempts < 0 || o.Retry.InitialBackoff < 0 ||
+		o.Retry.MaxBackoff < 0 || o.Retry.MaxRetryAfter < 0) {
+		return errors.New("retry attempts and durations must not be negative")
+	}
+
+	if o.CircuitBreaker != nil && (o.CircuitBreaker.FailureThreshold < 0 ||
+		o.CircuitBreaker.CoolDown < 0 || o.CircuitBreaker.HalfOpenRequests < 0) {
+		return errors.New("circuit breaker thresholds and cool-down must not be negative")
+	}
+
+	if o.KVRateLimit != nil {
+		for _, limit := range []*KVRateLimit{o.KVRateLimit.Read, o.KVRateLimit.Write,
+			o.KVRateLimit.List, o.KVRateLimit.Bulk, o.KVRateLimit.Total} {
+			if limit != nil && (limit.RequestsPerSecond <= 0 || limit.Burst < 0) {
+				return errors.New("KV rate limits must be positive")
+			}
+		}
+	}
+
+	for _, limit := range []*RateLimitOptions{o.LoginRateLimit, o.RegisterRateLimit} {
+		if limit == nil {
+			continue
+		}
+		if err := limit.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -76,3 +179,82 @@ func (o *ClientOptions) WithJWTExpirationHours(hours int) *ClientOptions {
 	o.JWTExpirationHours = hours
 	return o
 }
+
+// WithServiceTokenTTL sets the lifetime of service account tokens.
+func (o *ClientOptions) WithServiceTokenTTL(ttl time.Duration) *ClientOptions {
+	o.ServiceTokenTTL = ttl
+	return o
+}
+
+// WithSessionIdleTimeout enables sliding sessions that end after the given idle period.
+func (o *ClientOptions) WithSessionIdleTimeout(timeout time.Duration) *ClientOptions {
+	o.SessionIdleTimeout = timeout
+	return o
+}
+
+// WithSessionTouchInterval sets the minimum interval between session activity writes.
+func (o *ClientOptions) WithSessionTouchInterval(interval time.Duration) *ClientOptions {
+	o.SessionTouchInterval = interval
+	return o
+}
+
+// WithUserCache enables a local cache of user lookups made by ValidateToken.
+func (o *ClientOptions) WithUserCache(size int, ttl time.Duration) *ClientOptions {
+	o.UserCacheSize = size
+	o.UserCacheTTL = ttl
+	return o
+}
+
+// WithKVCache enables the local read-through KV cache.
+func (o *ClientOptions) WithKVCache(cache *KVCacheOptions) *ClientOptions {
+	o.KVCache = cache
+	return o
+}
+
+// WithRetryPolicy sets the retry policy for KV requests.
+func (o *ClientOptions) WithRetryPolicy(policy *RetryPolicy) *ClientOptions {
+	o.Retry = policy
+	return o
+}
+
+// WithCircuitBreaker enables the KV circuit breaker.
+func (o *ClientOptions) WithCircuitBreaker(breaker *CircuitBreakerOptions) *ClientOptions {
+	o.CircuitBreaker = breaker
+	return o
+}
+
+// WithKVRateLimit enables client-side rate limiting of KV requests.
+func (o *ClientOptions) WithKVRateLimit(limits *KVRateLimitOptions) *ClientOptions {
+	o.KVRateLimit = limits
+	return o
+}
+
+// WithLoginRateLimit limits failed login attempts per email address and client IP.
+func (o *ClientOptions) WithLoginRateLimit(limit *RateLimitOptions) *ClientOptions {
+	o.LoginRateLimit = limit
+	return o
+}
+
+// WithRegisterRateLimit limits registrations per client IP.
+func (o *ClientOptions) WithRegisterRateLimit(limit *RateLimitOptions) *ClientOptions {
+	o.RegisterRateLimit = limit
+	return o
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider.
+func (o *ClientOptions) WithTracerProvider(tp trace.TracerProvider) *ClientOptions {
+	o.TracerProvider = tp
+	return o
+}
+
+// WithMetrics sets the metrics recorder.
+func (o *ClientOptions) WithMetrics(m MetricsRecorder) *ClientOptions {
+	o.Metrics = m
+	return o
+}
+
+// WithLogger sets the structured logger.
+func (o *ClientOptions) WithLogger(logger *slog.Logger) *ClientOptions {
+	o.Logger = logger
+	return o
+}
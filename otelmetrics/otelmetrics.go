@@ -0,0 +1,114 @@
+// Package otelmetrics records Cloudflare Auth SDK metrics with the
+// OpenTelemetry metrics API.
+//
+// Basic usage:
+//
+//	metrics, err := otelmetrics.New(otel.GetMeterProvider())
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	client, err := cloudflare_auth_sdk.NewClient(opts.WithMetrics(metrics))
+//
+// The following instruments are created:
+//
+//	cfauth.logins{outcome}
+//	cfauth.registrations{outcome}
+//	cfauth.token_validations{outcome}
+//	cfauth.client_credentials{outcome}
+//	cfauth.kv.requests{operation, outcome}
+//	cfauth.kv.request.duration{operation, outcome} (seconds)
+package otelmetrics
+
+import (
+	"context"
+	"time"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// meterName is the instrumentation scope of the instruments.
+const meterName = "github.com/zolagz/cloudflare-auth-sdk"
+
+var (
+	attrOutcome   = attribute.Key("outcome")
+	attrOperation = attribute.Key("operation")
+)
+
+// Recorder implements sdk.MetricsRecorder with OpenTelemetry instruments.
+type Recorder struct {
+	logins           metric.Int64Counter
+	registrations    metric.Int64Counter
+	tokenValidations metric.Int64Counter
+	clientCreds      metric.Int64Counter
+	kvRequests       metric.Int64Counter
+	kvDuration       metric.Float64Histogram
+}
+
+var _ sdk.MetricsRecorder = (*Recorder)(nil)
+
+// New creates a recorder with instruments from mp.
+func New(mp metric.MeterProvider) (*Recorder, error) {
+	meter := mp.Meter(meterName)
+
+	var (
+		r   Recorder
+		err error
+	)
+	if r.logins, err = meter.Int64Counter("cfauth.logins",
+		metric.WithDescription("Login attempts by outcome.")); err != nil {
+		return nil, err
+	}
+	if r.registrations, err = meter.Int64Counter("cfauth.registrations",
+		metric.WithDescription("Registration attempts by outcome.")); err != nil {
+		return nil, err
+	}
+	if r.tokenValidations, err = meter.Int64Counter("cfauth.token_validations",
+		metric.WithDescription("Token validations by outcome.")); err != nil {
+		return nil, err
+	}
+	if r.clientCreds, err = meter.Int64Counter("cfauth.client_credentials",
+		metric.WithDescription("Service account client credentials exchanges by outcome.")); err != nil {
+		return nil, err
+	}
+	if r.kvRequests, err = meter.Int64Counter("cfauth.kv.requests",
+		metric.WithDescription("Cloudflare KV requests by operation and outcome.")); err != nil {
+		return nil, err
+	}
+	if r.kvDuration, err = meter.Float64Histogram("cfauth.kv.request.duration",
+		metric.WithDescription("Cloudflare KV request latency, including retries."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// RecordLogin implements sdk.MetricsRecorder.
+func (r *Recorder) RecordLogin(ctx context.Context, outcome string) {
+	r.logins.Add(ctx, 1, metric.WithAttributes(attrOutcome.String(outcome)))
+}
+
+// RecordRegistration implements sdk.MetricsRecorder.
+func (r *Recorder) RecordRegistration(ctx context.Context, outcome string) {
+	r.registrations.Add(ctx, 1, metric.WithAttributes(attrOutcome.String(outcome)))
+}
+
+// RecordTokenValidation implements sdk.MetricsRecorder.
+func (r *Recorder) RecordTokenValidation(ctx context.Context, outcome string) {
+	r.tokenValidations.Add(ctx, 1, metric.WithAttributes(attrOutcome.String(outcome)))
+}
+
+// RecordClientCredentials implements sdk.MetricsRecorder.
+func (r *Recorder) RecordClientCredentials(ctx context.Context, outcome string) {
+	r.clientCreds.Add(ctx, 1, metric.WithAttributes(attrOutcome.String(outcome)))
+}
+
+// RecordKVRequest implements sdk.MetricsRecorder.
+func (r *Recorder) RecordKVRequest(ctx context.Context, operation, outcome string, duration time.Duration) {
+	attrs := metric.WithAttributes(attrOperation.String(operation), attrOutcome.String(outcome))
+	r.kvRequests.Add(ctx, 1, attrs)
+	r.kvDuration.Record(ctx, duration.Seconds(), attrs)
+}
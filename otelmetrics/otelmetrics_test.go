@@ -0,0 +1,210 @@
+package otelmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newTestRecorder returns a recorder whose instruments are read by the
+// returned reader
+func newTestRecorder(t *testing.T) (*Recorder, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	r, err := New(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, reader
+}
+
+// collect returns the collected metrics by instrument name
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, scope := range rm.ScopeMetrics {
+		if scope.Scope.Name != meterName {
+			t.Errorf("instrumentation scope %q, want %q", scope.Scope.Name, meterName)
+		}
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+// counts returns the values of a counter by encoded attribute set, e.g.
+// "operation=KV.Get,outcome=success"
+func counts(t *testing.T, m metricdata.Metrics) map[string]int64 {
+	t.Helper()
+
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s is a %T, want an int64 sum", m.Name, m.Data)
+	}
+	if !sum.IsMonotonic || sum.Temporality != metricdata.CumulativeTemporality {
+		t.Errorf("%s is not a cumulative counter", m.Name)
+	}
+	values := make(map[string]int64)
+	for _, dp := range sum.DataPoints {
+		values[dp.Attributes.Encoded(attribute.DefaultEncoder())] = dp.Value
+	}
+	return values
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	r, reader := newTestRecorder(t)
+
+	r.RecordLogin(ctx, sdk.OutcomeSuccess)
+	r.RecordLogin(ctx, sdk.OutcomeSuccess)
+	r.RecordLogin(ctx, sdk.OutcomeInvalidCredentials)
+	r.RecordRegistration(ctx, sdk.OutcomeUserAlreadyExists)
+	r.RecordTokenValidation(ctx, sdk.OutcomeTokenExpired)
+	r.RecordClientCredentials(ctx, sdk.OutcomeSuccess)
+	r.RecordKVRequest(ctx, "KV.Get", sdk.OutcomeSuccess, 10*time.Millisecond)
+	r.RecordKVRequest(ctx, "KV.Get", sdk.OutcomeSuccess, 30*time.Millisecond)
+	r.RecordKVRequest(ctx, "KV.Put", sdk.OutcomeServerError, 2*time.Second)
+
+	metrics := collect(t, reader)
+
+	tests := []struct {
+		name string
+		want map[string]int64
+	}{
+		{name: "cfauth.logins", want: map[string]int64{"outcome=success": 2, "outcome=invalid_credentials": 1}},
+		{name: "cfauth.registrations", want: map[string]int64{"outcome=user_already_exists": 1}},
+		{name: "cfauth.token_validations", want: map[string]int64{"outcome=token_expired": 1}},
+		{name: "cfauth.client_credentials", want: map[string]int64{"outcome=success": 1}},
+		{name: "cfauth.kv.requests", want: map[string]int64{"operation=KV.Get,outcome=success": 2, "operation=KV.Put,outcome=server_error": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := metrics[tt.name]
+			if !ok {
+				t.Fatalf("%s was not collected", tt.name)
+			}
+			if m.Description == "" {
+				t.Errorf("%s has no description", tt.name)
+			}
+			got := counts(t, m)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%s = %v, want %v", tt.name, got, tt.want)
+			}
+			for attrs, want := range tt.want {
+				if got[attrs] != want {
+					t.Errorf("%s{%s} = %d, want %d", tt.name, attrs, got[attrs], want)
+				}
+			}
+		})
+	}
+
+	// Durations are recorded in seconds with the same attributes as the
+	// request counter
+	m := metrics["cfauth.kv.request.duration"]
+	if m.Unit != "s" {
+		t.Errorf("duration unit %q, want s", m.Unit)
+	}
+	hist, ok := m.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("cfauth.kv.request.duration is a %T, want a float64 histogram", m.Data)
+	}
+	type observed struct {
+		count uint64
+		sum   float64
+	}
+	got := make(map[string]observed)
+	for _, dp := range hist.DataPoints {
+		got[dp.Attributes.Encoded(attribute.DefaultEncoder())] = observed{dp.Count, dp.Sum}
+	}
+	want := map[string]observed{
+		"operation=KV.Get,outcome=success":      {2, 0.04},
+		"operation=KV.Put,outcome=server_error": {1, 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("duration series %v, want %v", got, want)
+	}
+	for attrs, w := range want {
+		g := got[attrs]
+		if g.count != w.count || g.sum < w.sum-1e-9 || g.sum > w.sum+1e-9 {
+			t.Errorf("cfauth.kv.request.duration{%s} = %d samples summing to %vs, want %d summing to %vs", attrs, g.count, g.sum, w.count, w.sum)
+		}
+	}
+}
+
+// TestRecorderWithClient checks the metrics a client records while KV fails
+// and its circuit breaker opens.
+func TestRecorderWithClient(t *testing.T) {
+	ctx := context.Background()
+	r, reader := newTestRecorder(t)
+
+	// Every KV request fails with 503
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  false,
+			"errors":   []interface{}{map[string]interface{}{"code": 10503, "message": "Service Unavailable"}},
+			"messages": []interface{}{},
+			"result":   nil,
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("CLOUDFLARE_BASE_URL", server.URL)
+
+	client, err := sdk.NewClient(&sdk.ClientOptions{
+		APIToken:       "test-token",
+		AccountID:      "test-account",
+		NamespaceID:    "test-namespace",
+		JWTSecret:      "test-secret-with-at-least-32-bytes",
+		CircuitBreaker: &sdk.CircuitBreakerOptions{FailureThreshold: 1, CoolDown: time.Hour},
+		Retry:          &sdk.RetryPolicy{MaxAttempts: 1},
+		Metrics:        r,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first login reaches KV and opens the breaker, the second is
+	// rejected by the open breaker
+	for i := 0; i < 2; i++ {
+		if _, err := client.Login(ctx, "alice@example.com", "password"); !sdk.IsKVOperationFailed(err) {
+			t.Fatalf("Login %d = %v, want a KV failure", i+1, err)
+		}
+	}
+	if state := client.CircuitState(); state != sdk.CircuitOpen {
+		t.Fatalf("circuit %v, want open", state)
+	}
+
+	metrics := collect(t, reader)
+	if got := counts(t, metrics["cfauth.logins"]); len(got) != 1 || got["outcome=storage_error"] != 2 {
+		t.Errorf("cfauth.logins = %v, want 2 storage errors", got)
+	}
+	got := counts(t, metrics["cfauth.kv.requests"])
+	want := map[string]int64{
+		"operation=KV.Get,outcome=server_error": 1,
+		"operation=KV.Get,outcome=unavailable":  1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("cfauth.kv.requests = %v, want %v", got, want)
+	}
+	for attrs, w := range want {
+		if got[attrs] != w {
+			t.Errorf("cfauth.kv.requests{%s} = %d, want %d", attrs, got[attrs], w)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package prommetrics records Cloudflare Auth SDK metrics with Prometheus.
+//
+// Basic usage:
+//
+//	metrics, err := prommetrics.New(prometheus.DefaultRegisterer, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	client, err := cloudflare_auth_sdk.NewClient(opts.WithMetrics(metrics))
+//
+// The following metrics are registered, with the default namespace:
+//
+//	cfauth_logins_total{outcome}
+//	cfauth_registrations_total{outcome}
+//	cfauth_token_validations_total{outcome}
+//	cfauth_client_credentials_total{outcome}
+//	cfauth_kv_requests_total{operation, outcome}
+//	cfauth_kv_request_duration_seconds{operation, outcome}
+package prommetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+)
+
+// Options contains options for the recorder.
+type Options struct {
+	Namespace string    // Metric name prefix (default: "cfauth")
+	Buckets   []float64 // KV latency histogram buckets in seconds (default: prometheus.DefBuckets)
+}
+
+// Recorder implements sdk.MetricsRecorder with Prometheus collectors.
+type Recorder struct {
+	logins           *prometheus.CounterVec
+	registrations    *prometheus.CounterVec
+	tokenValidations *prometheus.CounterVec
+	clientCreds      *prometheus.CounterVec
+	kvRequests       *prometheus.CounterVec
+	kvDuration       *prometheus.HistogramVec
+}
+
+var _ sdk.MetricsRecorder = (*Recorder)(nil)
+
+// New creates a recorder and registers its collectors with reg.
+//
+// Pass nil options to use the defaults.
+func New(reg prometheus.Registerer, opts *Options) (*Recorder, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "cfauth"
+	}
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+
+	r := &Recorder{
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by outcome.",
+		}, []string{"outcome"}),
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Registration attempts by outcome.",
+		}, []string{"outcome"}),
+		tokenValidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_validations_total",
+			Help:      "Token validations by outcome.",
+		}, []string{"outcome"}),
+		clientCreds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "client_credentials_total",
+			Help:      "Service account client credentials exchanges by outcome.",
+		}, []string{"outcome"}),
+		kvRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kv_requests_total",
+			Help:      "Cloudflare KV requests by operation and outcome.",
+		}, []string{"operation", "outcome"}),
+		kvDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "kv_request_duration_seconds",
+			Help:      "Cloudflare KV request latency by operation and outcome, including retries.",
+			Buckets:   buckets,
+		}, []string{"operation", "outcome"}),
+	}
+
+	for _, c := range []prometheus.Collector{r.logins, r.registrations, r.tokenValidations, r.clientCreds, r.kvRequests, r.kvDuration} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// RecordLogin implements sdk.MetricsRecorder.
+func (r *Recorder) RecordLogin(_ context.Context, outcome string) {
+	r.logins.WithLabelValues(outcome).Inc()
+}
+
+// RecordRegistration implements sdk.MetricsRecorder.
+func (r *Recorder) RecordRegistration(_ context.Context, outcome string) {
+	r.registrations.WithLabelValues(outcome).Inc()
+}
+
+// RecordTokenValidation implements sdk.MetricsRecorder.
+func (r *Recorder) RecordTokenValidation(_ context.Context, outcome string) {
+	r.tokenValidations.WithLabelValues(outcome).Inc()
+}
+
+// RecordClientCredentials implements sdk.MetricsRecorder.
+func (r *Recorder) RecordClientCredentials(_ context.Context, outcome string) {
+	r.clientCreds.WithLabelValues(outcome).Inc()
+}
+
+// RecordKVRequest implements sdk.MetricsRecorder.
+func (r *Recorder) RecordKVRequest(_ context.Context, operation, outcome string, duration time.Duration) {
+	r.kvRequests.WithLabelValues(operation, outcome).Inc()
+	r.kvDuration.WithLabelValues(operation, outcome).Observe(duration.Seconds())
+}
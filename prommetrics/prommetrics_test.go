@@ -0,0 +1,61 @@
+package prommetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdk "github.com/zolagz/cloudflare-auth-sdk"
+)
+
+func TestRecordKVRequestLabelsDurationByOutcome(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r, err := New(reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r.RecordKVRequest(ctx, "KV.Get", sdk.OutcomeSuccess, 10*time.Millisecond)
+	r.RecordKVRequest(ctx, "KV.Get", sdk.OutcomeTimeout, 5*time.Second)
+	r.RecordKVRequest(ctx, "KV.Get", sdk.OutcomeTimeout, 5*time.Second)
+
+	tests := []struct {
+		outcome string
+		want    int
+	}{
+		{sdk.OutcomeSuccess, 1},
+		{sdk.OutcomeTimeout, 2},
+	}
+	for _, tt := range tests {
+		if n := testutil.ToFloat64(r.kvRequests.WithLabelValues("KV.Get", tt.outcome)); int(n) != tt.want {
+			t.Errorf("kv_requests_total{outcome=%q} = %v, want %d", tt.outcome, n, tt.want)
+		}
+	}
+
+	// Failed requests are observed separately from successful ones
+	if n := testutil.CollectAndCount(r.kvDuration, "cfauth_kv_request_duration_seconds"); n != 2 {
+		t.Fatalf("%d duration series, want one per outcome", n)
+	}
+	if _, err := r.kvDuration.GetMetricWith(prometheus.Labels{"operation": "KV.Get"}); err == nil {
+		t.Fatal("duration histogram accepts samples without an outcome")
+	}
+}
+
+func TestRecordClientCredentials(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r, err := New(reg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r.RecordClientCredentials(ctx, sdk.OutcomeSuccess)
+	r.RecordClientCredentials(ctx, sdk.OutcomeInvalidCredentials)
+
+	if n := testutil.CollectAndCount(reg, "cfauth_client_credentials_total"); n != 2 {
+		t.Fatalf("%d client_credentials_total series, want one per outcome", n)
+	}
+}
@@ -0,0 +1,331 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitAlgorithm selects how a RateLimiter counts requests.
+type RateLimitAlgorithm int
+
+const (
+	// FixedWindow counts requests in consecutive windows of fixed length.
+	FixedWindow RateLimitAlgorithm = iota
+	// SlidingWindow weights the previous window's count by its overlap with
+	// a window ending now, smoothing bursts at window boundaries.
+	SlidingWindow
+)
+
+// RateLimitOptions contains options for a RateLimiter.
+type RateLimitOptions struct {
+	Name      string             // Counter namespace in KV (default: "default")
+	Limit     int                // Requests allowed per window
+	Window    time.Duration      // Window length, at least one second
+	Algorithm RateLimitAlgorithm // Counting algorithm (default: FixedWindow)
+}
+
+// RateLimitResult describes the outcome of a rate limit check.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           // Requests left in the current window
+	ResetAt    time.Time     // End of the current window
+	RetryAfter time.Duration // Time until a request may be allowed; zero if allowed
+}
+
+// RateLimiter limits requests per identifier using counters stored in KV.
+//
+// Counters are kept under "ratelimit:<name>:<hash>:<window>" keys that expire
+// on their own once they are no longer needed. The hash is an HMAC of the
+// identifier keyed with the client's JWT secret, so identifiers such as
+// email and IP addresses do not appear in key listings; rotating the secret
+// resets all counters. Workers KV has no atomic
+// increment, so concurrent requests for the same identifier may be
+// under-counted; the limiter is meant for abuse protection, not exact quotas.
+type RateLimiter struct {
+	client *Client
+	opts   RateLimitOptions
+}
+
+// rateLimitedError carries the result of a denied rate limit check.
+type rateLimitedError struct {
+	result *RateLimitResult
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("%v: retry after %s", ErrRateLimited, e.result.RetryAfter.Round(time.Second))
+}
+
+func (e *rateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// NewRateLimiter creates a rate limiter that stores its counters in the
+// client's KV namespace.
+//
+// Example:
+//
+//	limiter, err := client.NewRateLimiter(&sdk.RateLimitOptions{
+//	    Name:      "api",
+//	    Limit:     100,
+//	    Window:    time.Minute,
+//	    Algorithm: sdk.SlidingWindow,
+//	})
+func (c *Client) NewRateLimiter(opts *RateLimitOptions) (*RateLimiter, error) {
+	if opts == nil {
+		return nil, ErrInvalidConfig
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	limiterOpts := *opts
+	if limiterOpts.Name == "" {
+		limiterOpts.Name = "default"
+	}
+
+	return &RateLimiter{
+		client: c,
+		opts:   limiterOpts,
+	}, nil
+}
+
+// validate checks the rate limit options
+func (o *RateLimitOptions) validate() error {
+	if o.Limit <= 0 {
+		return errors.New("rate limit must be positive")
+	}
+	if o.Window < time.Second {
+		return errors.New("rate limit window must be at least one second")
+	}
+	if o.Algorithm != FixedWindow && o.Algorithm != SlidingWindow {
+		return errors.New("unknown rate limit algorithm")
+	}
+	return nil
+}
+
+// Allow counts a request for id and reports whether it is within the limit.
+//
+// Denied requests are not counted. A denied check returns the result
+// together with an AppError wrapping ErrRateLimited (429).
+func (rl *RateLimiter) Allow(ctx context.Context, id string) (*RateLimitResult, error) {
+	return rl.check(ctx, "RateLimiter.Allow", id, true, rl.client.now())
+}
+
+// check reports whether a request for id at now is within the limit and,
+// if it is and count is set, counts it
+func (rl *RateLimiter) check(ctx context.Context, op, id string, count bool, now time.Time) (*RateLimitResult, error) {
+	window := rl.opts.Window
+	index := now.UnixNano() / int64(window)
+	windowStart := time.Unix(0, index*int64(window))
+	resetAt := windowStart.Add(window)
+
+	current, err := rl.count(ctx, id, index)
+	if err != nil {
+		return nil, kvError(op, err, "failed to read rate limit counter")
+	}
+
+	used := float64(current)
+	var previous int
+	if rl.opts.Algorithm == SlidingWindow {
+		previous, err = rl.count(ctx, id, index-1)
+		if err != nil {
+			return nil, kvError(op, err, "failed to read rate limit counter")
+		}
+		overlap := 1 - float64(now.Sub(windowStart))/float64(window)
+		used += float64(previous) * overlap
+	}
+
+	result := &RateLimitResult{
+		Limit:   rl.opts.Limit,
+		ResetAt: resetAt,
+	}
+
+	if int(math.Ceil(used)) >= rl.opts.Limit {
+		result.RetryAfter = rl.retryAfter(now, windowStart, current, previous)
+		return result, NewAppError(op, &rateLimitedError{result: result}, "rate limit exceeded", 429)
+	}
+
+	result.Allowed = true
+	result.Remaining = rl.opts.Limit - int(math.Ceil(used))
+	if !count {
+		return result, nil
+	}
+
+	if err := rl.store(ctx, id, index, current+1); err != nil {
+		return nil, kvError(op, err, "failed to update rate limit counter")
+	}
+	result.Remaining--
+	return result, nil
+}
+
+// Reset clears the counters of id.
+func (rl *RateLimiter) Reset(ctx context.Context, id string) error {
+	const op = "RateLimiter.Reset"
+
+	index := rl.client.now().UnixNano() / int64(rl.opts.Window)
+	if err := rl.client.kvDeleteBulk(ctx, []string{rl.key(id, index-1), rl.key(id, index)}); err != nil {
+		return kvError(op, err, "failed to reset rate limit counters")
+	}
+	return nil
+}
+
+// retryAfter estimates the time until a request for the identifier is allowed
+func (rl *RateLimiter) retryAfter(now, windowStart time.Time, current, previous int) time.Duration {
+	window := rl.opts.Window
+	untilReset := windowStart.Add(window).Sub(now)
+
+	if rl.opts.Algorithm == FixedWindow || current >= rl.opts.Limit || previous == 0 {
+		return untilReset
+	}
+
+	// The previous window's weight drops linearly; find when the weighted
+	// count falls below the limit within the current window
+	free := float64(rl.opts.Limit-current) / float64(previous)
+	elapsed := time.Duration((1 - free) * float64(window))
+	wait := windowStart.Add(elapsed).Sub(now)
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if wait > untilReset {
+		wait = untilReset
+	}
+	return wait
+}
+
+// count reads a counter, bypassing the KV cache
+func (rl *RateLimiter) count(ctx context.Context, id string, index int64) (int, error) {
+	data, err := rl.client.kvFetch(ctx, rl.key(id, index))
+	if err != nil {
+		if isKVNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(string(data))
+	if err != nil {
+		// A corrupt counter is treated as empty rather than blocking the id
+		return 0, nil
+	}
+	return n, nil
+}
+
+// store writes a counter with a TTL covering the window and the next one,
+// which the sliding window still reads
+func (rl *RateLimiter) store(ctx context.Context, id string, index int64, n int) error {
+	ttl := 2 * rl.opts.Window
+	if ttl < minKVExpirationTTL {
+		ttl = minKVExpirationTTL
+	}
+
+	return rl.client.kvSet(ctx, rl.key(id, index), []byte(strconv.Itoa(n)), &KVWriteOptions{
+		ExpirationTTL: int((ttl + time.Second - 1) / time.Second),
+	})
+}
+
+func (rl *RateLimiter) key(id string, index int64) string {
+	mac := hmac.New(sha256.New, rl.client.jwtSecret)
+	mac.Write([]byte(id))
+	return fmt.Sprintf("ratelimit:%s:%s:%d", rl.opts.Name, hex.EncodeToString(mac.Sum(nil)), index)
+}
+
+// newAuthLimiter returns the limiter for LoginRateLimit or RegisterRateLimit,
+// or nil if opts is nil
+func (c *Client) newAuthLimiter(opts *RateLimitOptions, name string) *RateLimiter {
+	if opts == nil {
+		return nil
+	}
+	limiterOpts := *opts
+	if limiterOpts.Name == "" {
+		limiterOpts.Name = name
+	}
+	return &RateLimiter{client: c, opts: limiterOpts}
+}
+
+// loginThrottleID identifies the login attempts counted together: those for
+// one email address from one client IP. It reports false when ctx carries no
+// client IP; keying on the address alone would let anyone lock a user out.
+func loginThrottleID(ctx context.Context, email string) (string, bool) {
+	ip, ok := ClientIPFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return ip + "|" + strings.ToLower(email), true
+}
+
+// recordLoginFailure counts a failed login attempt; a counter that cannot
+// be updated is logged rather than failing the login
+func (c *Client) recordLoginFailure(ctx context.Context, id string) {
+	if c.loginLimiter == nil || id == "" {
+		return
+	}
+	if _, err := c.loginLimiter.check(ctx, "Client.Login", id, true, c.now()); err != nil && !IsRateLimited(err) {
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "failed login not counted",
+			slog.String(logKeyOp, "Client.Login"),
+			slog.String(logKeyOutcome, kvOutcome(err)),
+		)
+	}
+}
+
+// Middleware returns net/http middleware that rate limits requests by the
+// identifier returned by keyFunc, such as RateLimitByIP.
+//
+// Every response carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers; denied requests get 429 with Retry-After. If the
+// counters cannot be read, requests are rejected with the storage error.
+func (rl *RateLimiter) Middleware(keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := rl.Allow(r.Context(), keyFunc(r))
+			if result != nil {
+				setRateLimitHeaders(w.Header(), result)
+			}
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByIP returns the client IP of the request from RemoteAddr.
+//
+// Behind a proxy, use a key function that reads the proxy's client IP
+// header instead.
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// setRateLimitHeaders writes the RateLimit-* headers for a result
+func setRateLimitHeaders(h http.Header, result *RateLimitResult) {
+	reset := int64(math.Ceil(time.Until(result.ResetAt).Seconds()))
+	if reset < 0 {
+		reset = 0
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if !result.Allowed {
+		retryAfter := int64(math.Ceil(result.RetryAfter.Seconds()))
+		h.Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	}
+}
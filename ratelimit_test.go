@@ -0,0 +1,246 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWindows(t *testing.T) {
+	window := time.Minute
+	index := time.Now().UnixNano() / int64(window)
+	windowStart := time.Unix(0, index*int64(window))
+
+	tests := []struct {
+		name          string
+		algorithm     RateLimitAlgorithm
+		previous      int
+		current       int
+		offset        time.Duration // Time of the request into the current window
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "fixed: under the limit", algorithm: FixedWindow, current: 3, offset: 10 * time.Second, wantAllowed: true, wantRemaining: 6},
+		{name: "fixed: last request", algorithm: FixedWindow, current: 9, offset: 10 * time.Second, wantAllowed: true, wantRemaining: 0},
+		{name: "fixed: at the limit", algorithm: FixedWindow, current: 10, offset: 10 * time.Second, wantRetry: 50 * time.Second},
+		{name: "fixed: ignores the previous window", algorithm: FixedWindow, previous: 10, offset: time.Second, wantAllowed: true, wantRemaining: 9},
+		{name: "sliding: previous window weighted by overlap", algorithm: SlidingWindow, previous: 10, offset: 15 * time.Second, wantAllowed: true, wantRemaining: 1},
+		{name: "sliding: previous window still full", algorithm: SlidingWindow, previous: 10, offset: time.Millisecond, wantRetry: time.Second},
+		{name: "sliding: waits for the previous weight to drop", algorithm: SlidingWindow, previous: 10, current: 5, offset: 15 * time.Second, wantRetry: 15 * time.Second},
+		{name: "sliding: current window full", algorithm: SlidingWindow, previous: 4, current: 10, offset: 15 * time.Second, wantRetry: 45 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, kv := newTestClient(t, nil)
+			rl, err := client.NewRateLimiter(&RateLimitOptions{Limit: 10, Window: window, Algorithm: tt.algorithm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			kv.put(rl.key("id", index-1), []byte(strconv.Itoa(tt.previous)))
+			kv.put(rl.key("id", index), []byte(strconv.Itoa(tt.current)))
+
+			result, err := rl.check(context.Background(), "test", "id", true, windowStart.Add(tt.offset))
+			if result == nil {
+				t.Fatalf("check: %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || (err == nil) != tt.wantAllowed {
+				t.Fatalf("allowed = %v, %v; want %v", result.Allowed, err, tt.wantAllowed)
+			}
+			if tt.wantAllowed && result.Remaining != tt.wantRemaining {
+				t.Errorf("remaining = %d, want %d", result.Remaining, tt.wantRemaining)
+			}
+			if !tt.wantAllowed && (!IsRateLimited(err) || result.RetryAfter != tt.wantRetry) {
+				t.Errorf("denied with %v, retry after %v; want rate limited, retry after %v", err, result.RetryAfter, tt.wantRetry)
+			}
+			if !result.ResetAt.Equal(windowStart.Add(window)) {
+				t.Errorf("reset at %v, want the end of the window", result.ResetAt)
+			}
+
+			// Only allowed requests are counted
+			want := tt.current
+			if tt.wantAllowed {
+				want++
+			}
+			if value, _ := kv.get(rl.key("id", index)); string(value) != strconv.Itoa(want) {
+				t.Errorf("counter = %s, want %d", value, want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterKeysHideIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	newTestClock(client)
+	rl, err := client.NewRateLimiter(&RateLimitOptions{Name: "api", Limit: 10, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"alice@example.com", "198.51.100.1"} {
+		if _, err := rl.Allow(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := kv.keys("ratelimit:api:")
+	if len(keys) != 2 {
+		t.Fatalf("counters %v, want 2", keys)
+	}
+	for _, key := range keys {
+		if strings.Contains(key, "alice") || strings.Contains(key, "198.51") {
+			t.Errorf("counter key %q contains the identifier", key)
+		}
+	}
+
+	if err := rl.Reset(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := kv.keys("ratelimit:api:"); len(keys) != 1 {
+		t.Fatalf("counters %v after Reset, want 1", keys)
+	}
+}
+
+func TestLoginRateLimitCountsFailuresPerClient(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, &ClientOptions{
+		LoginRateLimit: &RateLimitOptions{Limit: 2, Window: time.Minute},
+	})
+	clock := newTestClock(client)
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	attacker := NewClientIPContext(ctx, "198.51.100.1")
+	user := NewClientIPContext(ctx, "203.0.113.7")
+
+	// Successful logins are not counted
+	for i := 0; i < 3; i++ {
+		if _, err := client.Login(user, "alice@example.com", "password"); err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Login(attacker, "alice@example.com", "guess"); !IsInvalidCredentials(err) {
+			t.Fatalf("failed login %d = %v, want invalid credentials", i, err)
+		}
+	}
+	for _, email := range []string{"alice@example.com", "ALICE@example.com"} {
+		if _, err := client.Login(attacker, email, "password"); !IsRateLimited(err) {
+			t.Fatalf("login as %s after too many failures = %v, want rate limited", email, err)
+		}
+	}
+
+	// The user is not locked out from another address
+	if _, err := client.Login(user, "alice@example.com", "password"); err != nil {
+		t.Fatalf("login from another client: %v", err)
+	}
+
+	// Unknown addresses count as failures too
+	for i := 0; i < 2; i++ {
+		if _, err := client.Login(attacker, "nobody@example.com", "guess"); !IsInvalidCredentials(err) {
+			t.Fatalf("login for an unknown user %d = %v", i, err)
+		}
+	}
+	if _, err := client.Login(attacker, "nobody@example.com", "guess"); !IsRateLimited(err) {
+		t.Fatalf("login for an unknown user after too many failures = %v, want rate limited", err)
+	}
+
+	// Failures are forgotten in the next window
+	clock.advance(time.Minute)
+	if _, err := client.Login(attacker, "alice@example.com", "password"); err != nil {
+		t.Fatalf("login in the next window: %v", err)
+	}
+}
+
+func TestRegisterRateLimitPerClientIP(t *testing.T) {
+	client, _ := newTestClient(t, &ClientOptions{
+		RegisterRateLimit: &RateLimitOptions{Limit: 1, Window: time.Minute},
+	})
+	newTestClock(client)
+	handler := client.Handler(&MiddlewareOptions{
+		ClientIP: func(r *http.Request) string { return r.Header.Get("X-Real-IP") },
+	})
+
+	register := func(ip, email string) int {
+		body := `{"email":"` + email + `","password":"password"}`
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+		req.Header.Set("X-Real-IP", ip)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		ip, email  string
+		wantStatus int
+	}{
+		{"198.51.100.1", "alice@example.com", http.StatusCreated},
+		{"198.51.100.1", "bob@example.com", http.StatusTooManyRequests},
+		{"203.0.113.7", "bob@example.com", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		if status := register(tt.ip, tt.email); status != tt.wantStatus {
+			t.Errorf("register %s from %s: status %d, want %d", tt.email, tt.ip, status, tt.wantStatus)
+		}
+	}
+}
+
+func TestAuthRateLimitsSkipCallsWithoutClientIP(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{
+		LoginRateLimit:    &RateLimitOptions{Limit: 1, Window: time.Minute},
+		RegisterRateLimit: &RateLimitOptions{Limit: 1, Window: time.Minute},
+	})
+	clock := newTestClock(client)
+
+	// Without a client IP, callers do not share one counter
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		if _, err := client.Register(ctx, email, "password"); err != nil {
+			t.Fatalf("register %s: %v", email, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Login(ctx, "alice@example.com", "guess"); !IsInvalidCredentials(err) {
+			t.Fatalf("failed login %d = %v, want invalid credentials", i, err)
+		}
+	}
+	if _, err := client.Login(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatalf("login after failures without a client IP: %v", err)
+	}
+	if keys := kv.keys("ratelimit:"); len(keys) != 0 {
+		t.Fatalf("counters %v written for calls without a client IP", keys)
+	}
+
+	// Calls with one are still throttled
+	attacker := NewClientIPContext(ctx, "198.51.100.1")
+	if _, err := client.Login(attacker, "alice@example.com", "guess"); !IsInvalidCredentials(err) {
+		t.Fatalf("failed login = %v, want invalid credentials", err)
+	}
+	if _, err := client.Login(attacker, "alice@example.com", "password"); !IsRateLimited(err) {
+		t.Fatalf("login after too many failures = %v, want rate limited", err)
+	}
+	if _, err := client.Register(attacker, "dave@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Register(attacker, "erin@example.com", "password"); !IsRateLimited(err) {
+		t.Fatalf("second registration from one IP = %v, want rate limited", err)
+	}
+
+	// The counters start over in the next window
+	clock.advance(time.Minute)
+	if _, err := client.Register(attacker, "erin@example.com", "password"); err != nil {
+		t.Fatalf("registration in the next window: %v", err)
+	}
+	if _, err := client.Login(attacker, "alice@example.com", "password"); err != nil {
+		t.Fatalf("login in the next window: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go/v6"
+)
+
+const (
+	// defaultRetryMaxAttempts is the default number of attempts per KV request.
+	defaultRetryMaxAttempts = 3
+
+	// defaultRetryInitialBackoff is the default backoff before the first retry.
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+
+	// defaultRetryMaxBackoff is the default upper bound of a single backoff.
+	defaultRetryMaxBackoff = 5 * time.Second
+
+	// defaultRetryMaxRetryAfter is the default longest Retry-After that is honoured.
+	defaultRetryMaxRetryAfter = 30 * time.Second
+)
+
+// RetryPolicy configures automatic retries of KV requests.
+//
+// Requests are retried on network errors and on 429 and 5xx responses, with
+// exponential backoff and full jitter. A Retry-After header sent by
+// Cloudflare replaces the computed backoff when it is longer.
+//
+// Retries apply to single KV requests, which are all idempotent: reads,
+// deletes and writes of a fixed key and value. Multi-step flows such as
+// Register are never repeated as a whole.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first (default: 3)
+	InitialBackoff time.Duration // Backoff before the first retry (default: 100ms)
+	MaxBackoff     time.Duration // Upper bound of a single computed backoff (default: 5s)
+
+	// MaxRetryAfter is the longest Retry-After delay that is waited for;
+	// longer delays fail the request immediately (default: 30s).
+	MaxRetryAfter time.Duration
+}
+
+// withDefaults returns a copy of the policy with defaults applied
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if p.MaxRetryAfter == 0 {
+		p.MaxRetryAfter = defaultRetryMaxRetryAfter
+	}
+	return p
+}
+
+// backoff returns the jittered delay before the given retry (1-based)
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.MaxBackoff
+	if shift := retry - 1; shift < 32 {
+		if d := p.InitialBackoff << shift; d > 0 && d < limit {
+			limit = d
+		}
+	}
+	return time.Duration(rand.Int64N(int64(limit) + 1))
+}
+
+// kvRetry runs an idempotent KV request, retrying it according to the
+// client's retry policy. Every attempt waits for the rate limiter first.
+//
+// It stops early when the context is done or when the next attempt would
+// start after the context deadline, returning the last request error.
+func (c *Client) kvRetry(ctx context.Context, class KVOperationClass, fn func(context.Context) error) error {
+	if c.retry == nil {
+		return c.kvAttempt(ctx, class, fn)
+	}
+
+	policy := c.retry
+	for attempt := 1; ; attempt++ {
+		err := c.kvAttempt(ctx, class, fn)
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		if retryAfter, ok := retryAfterDelay(err); ok {
+			if retryAfter > policy.MaxRetryAfter {
+				return err
+			}
+			if retryAfter > wait {
+				wait = retryAfter
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// kvAttempt makes one KV request once the rate limiter allows it
+func (c *Client) kvAttempt(ctx context.Context, class KVOperationClass, fn func(context.Context) error) error {
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.wait(ctx, class); err != nil {
+			return err
+		}
+	}
+	return fn(ctx)
+}
+
+// isRetryable reports whether a failed request may be retried
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *cloudflare.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfterDelay reads the Retry-After delay from a Cloudflare API error
+func retryAfterDelay(err error) (time.Duration, bool) {
+	var apiErr *cloudflare.Error
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return 0, false
+	}
+
+	header := apiErr.Response.Header
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
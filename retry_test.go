@@ -0,0 +1,196 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go/v6"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}.withDefaults()
+
+	tests := []struct {
+		retry int
+		limit time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second}, // The shift would overflow
+	}
+
+	for _, tt := range tests {
+		var longest time.Duration
+		for i := 0; i < 1000; i++ {
+			d := policy.backoff(tt.retry)
+			if d < 0 || d > tt.limit {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v]", tt.retry, d, tt.limit)
+			}
+			longest = max(longest, d)
+		}
+		// Full jitter spreads over the whole range
+		if longest < tt.limit/2 {
+			t.Errorf("backoff(%d) never exceeded %v in 1000 draws, want up to %v", tt.retry, longest, tt.limit)
+		}
+	}
+}
+
+// apiError returns a Cloudflare API error with the given status and headers
+func apiError(status int, header http.Header) error {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &cloudflare.Error{
+		StatusCode: status,
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+// kvNotFoundError returns the error of a read of a missing key
+func kvNotFoundError() error {
+	return &cloudflare.Error{
+		Errors:     []cloudflare.ErrorData{{Code: kvKeyNotFoundCode}},
+		StatusCode: http.StatusNotFound,
+		Response:   &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}},
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "no header", err: apiError(503, nil)},
+		{name: "seconds", err: apiError(429, http.Header{"Retry-After": {"2"}}), want: 2 * time.Second, wantOK: true},
+		{name: "fractional seconds", err: apiError(429, http.Header{"Retry-After": {"0.5"}}), want: 500 * time.Millisecond, wantOK: true},
+		{name: "milliseconds first", err: apiError(429, http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"2"}}), want: 250 * time.Millisecond, wantOK: true},
+		{name: "past date", err: apiError(503, http.Header{"Retry-After": {"Mon, 02 Jan 2006 15:04:05 GMT"}}), want: 0, wantOK: true},
+		{name: "negative", err: apiError(503, http.Header{"Retry-After": {"-1"}})},
+		{name: "garbage", err: apiError(503, http.Header{"Retry-After": {"soon"}})},
+		{name: "wrapped", err: fmt.Errorf("get: %w", apiError(429, http.Header{"Retry-After": {"1"}})), want: time.Second, wantOK: true},
+		{name: "not an API error", err: io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfterDelay(tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("retryAfterDelay = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// A future date counts down from now
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	got, ok := retryAfterDelay(apiError(503, http.Header{"Retry-After": {date}}))
+	if !ok || got <= 8*time.Second || got > 10*time.Second {
+		t.Fatalf("retryAfterDelay(%s) = %v, %v; want about 10s", date, got, ok)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", apiError(429, nil), true},
+		{"server error", apiError(500, nil), true},
+		{"unavailable", apiError(503, nil), true},
+		{"bad request", apiError(400, nil), false},
+		{"not found", apiError(404, nil), false},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKVRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name         string
+		statuses     []int // Status of each attempt; 0 serves the request
+		header       http.Header
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "succeeds after 5xx", statuses: []int{503, 502, 0}, wantAttempts: 3},
+		{name: "gives up after max attempts", statuses: []int{503, 503, 503, 0}, wantAttempts: 3, wantErr: true},
+		{name: "4xx is not retried", statuses: []int{400, 0}, wantAttempts: 1, wantErr: true},
+		{name: "honours a short Retry-After", statuses: []int{429, 0}, header: http.Header{"Retry-After-Ms": {"5"}}, wantAttempts: 2},
+		{name: "long Retry-After fails fast", statuses: []int{429, 0}, header: http.Header{"Retry-After": {"3600"}}, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, kv := newTestClient(t, &ClientOptions{Retry: policy})
+			kv.put("item:1", []byte("v"))
+
+			var attempts atomic.Int32
+			serveKV(t, client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if status := tt.statuses[n-1]; status != 0 {
+					for name, values := range tt.header {
+						w.Header()[name] = values
+					}
+					writeFakeKVError(w, status, "injected failure")
+					return
+				}
+				kv.ServeHTTP(w, r)
+			}))
+
+			value, err := client.KVGet(context.Background(), "item:1")
+			if (err != nil) != tt.wantErr || (err == nil && string(value) != "v") {
+				t.Fatalf("KVGet = %q, %v; want error %v", value, err, tt.wantErr)
+			}
+			if n := attempts.Load(); n != tt.wantAttempts {
+				t.Fatalf("%d attempts, want %d", n, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestKVRetryStopsAtDeadline(t *testing.T) {
+	client, _ := newTestClient(t, &ClientOptions{Retry: &RetryPolicy{MaxAttempts: 5}})
+
+	var attempts atomic.Int32
+	serveKV(t, client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "1")
+		writeFakeKVError(w, http.StatusTooManyRequests, "slow down")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.KVGet(ctx, "item:1")
+	if !errors.Is(err, ErrKVOperationFailed) {
+		t.Fatalf("KVGet = %v, want ErrKVOperationFailed", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("%d attempts, want 1: the retry would start after the deadline", n)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("KVGet waited %v before giving up", elapsed)
+	}
+}
@@ -0,0 +1,427 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// serviceAccountIDPrefix starts every service account ID, keeping them
+	// apart from user IDs.
+	serviceAccountIDPrefix = "sa_"
+
+	// serviceAccountSecretBytes is the length of the random client secret.
+	serviceAccountSecretBytes = 32
+
+	// defaultServiceTokenTTL is the default lifetime of service account tokens.
+	defaultServiceTokenTTL = 15 * time.Minute
+)
+
+// ServiceAccount is a non-human principal, such as a CI pipeline or a
+// daemon. It holds roles and API keys but has no email or password, so it
+// cannot log in; it obtains tokens with ExchangeClientCredentials instead.
+type ServiceAccount struct {
+	ID          string    `json:"id"` // Also the client ID, e.g. "sa_<uuid>"
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	Disabled    bool      `json:"disabled,omitempty"` // Disabled accounts get no tokens and their API keys are rejected
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ServiceAccountOptions contains the settable fields of a service account,
+// used by CreateServiceAccount and UpdateServiceAccount.
+type ServiceAccountOptions struct {
+	Name        string   // Display name (required)
+	Description string   // Free-form description
+	Roles       []string // Roles granted to the account
+	Disabled    bool     // Blocks tokens and API keys of the account
+}
+
+// ServiceAccountCredentials is a service account together with its client
+// secret, returned when the secret is created or rotated.
+type ServiceAccountCredentials struct {
+	ServiceAccount
+	ClientSecret string `json:"client_secret"` // It cannot be retrieved again
+}
+
+// ServiceTokenResponse is the result of a client credentials exchange.
+type ServiceTokenResponse struct {
+	Token          string          `json:"token"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+	ServiceAccount *ServiceAccount `json:"service_account"`
+}
+
+// serviceAccountRecord is the stored form of a service account.
+type serviceAccountRecord struct {
+	ServiceAccount
+	SecretHash string `json:"secret_hash"` // Hex SHA-256 of the client secret
+}
+
+// HasRole reports whether the account was granted role.
+func (sa *ServiceAccount) HasRole(role string) bool {
+	for _, r := range sa.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsServiceAccountID reports whether id names a service account rather
+// than a user.
+func IsServiceAccountID(id string) bool {
+	return strings.HasPrefix(id, serviceAccountIDPrefix)
+}
+
+// CreateServiceAccount creates a service account and its client secret.
+//
+// Only a SHA-256 hash of the secret is stored, so the returned ClientSecret
+// must be handed to the account's operator right away.
+//
+// Example:
+//
+//	creds, err := client.CreateServiceAccount(ctx, &sdk.ServiceAccountOptions{
+//	    Name:  "deploy-pipeline",
+//	    Roles: []string{"deployer"},
+//	})
+//	// Configure the pipeline with creds.ID and creds.ClientSecret
+func (c *Client) CreateServiceAccount(ctx context.Context, opts *ServiceAccountOptions) (_ *ServiceAccountCredentials, err error) {
+	const op = "Client.CreateServiceAccount"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if opts == nil || strings.TrimSpace(opts.Name) == "" {
+		return nil, NewAppError(op, ErrInvalidInput, "service account name is required", 400)
+	}
+
+	secret, err := newServiceAccountSecret()
+	if err != nil {
+		return nil, NewAppError(op, err, "failed to generate client secret", 500)
+	}
+
+	now := time.Now()
+	record := &serviceAccountRecord{
+		ServiceAccount: ServiceAccount{
+			ID:        serviceAccountIDPrefix + uuid.New().String(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		SecretHash: hashSecret(secret),
+	}
+	record.apply(opts)
+
+	if err := c.saveServiceAccount(ctx, record); err != nil {
+		return nil, kvError(op, err, "failed to save service account")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditServiceAccountCreated, UserID: record.ID})
+
+	return &ServiceAccountCredentials{ServiceAccount: record.ServiceAccount, ClientSecret: secret}, nil
+}
+
+// GetServiceAccount retrieves a service account by ID.
+func (c *Client) GetServiceAccount(ctx context.Context, id string) (_ *ServiceAccount, err error) {
+	const op = "Client.GetServiceAccount"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	record, err := c.loadServiceAccount(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+	return &record.ServiceAccount, nil
+}
+
+// ListServiceAccounts returns all service accounts, without their secrets.
+func (c *Client) ListServiceAccounts(ctx context.Context) (_ []ServiceAccount, err error) {
+	const op = "Client.ListServiceAccounts"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	var accounts []ServiceAccount
+	prefix := getServiceAccountKey("")
+	cursor := ""
+	for {
+		page, next, err := c.kvList(ctx, prefix, kvListMaxLimit, cursor)
+		if err != nil {
+			return nil, kvError(op, err, "failed to list service accounts")
+		}
+
+		for _, item := range page {
+			record, err := c.getServiceAccount(ctx, strings.TrimPrefix(item.Name, prefix))
+			if err != nil {
+				if isKVNotFound(err) {
+					// Deleted while listing
+					continue
+				}
+				return nil, kvError(op, err, "failed to read service account")
+			}
+			accounts = append(accounts, record.ServiceAccount)
+		}
+
+		if next == "" {
+			return accounts, nil
+		}
+		cursor = next
+	}
+}
+
+// UpdateServiceAccount replaces the name, description, roles and disabled
+// state of a service account.
+//
+// Role changes apply to new tokens; tokens already issued keep the roles
+// they were issued with until they expire. Disabling an account rejects
+// its existing tokens and API keys at once.
+//
+// Disabling an account is audited as AuditServiceAccountDisabled, any
+// other update as AuditServiceAccountUpdated.
+func (c *Client) UpdateServiceAccount(ctx context.Context, id string, opts *ServiceAccountOptions) (_ *ServiceAccount, err error) {
+	const op = "Client.UpdateServiceAccount"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if opts == nil || strings.TrimSpace(opts.Name) == "" {
+		return nil, NewAppError(op, ErrInvalidInput, "service account name is required", 400)
+	}
+
+	record, err := c.loadServiceAccount(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	action := AuditServiceAccountUpdated
+	if opts.Disabled && !record.Disabled {
+		action = AuditServiceAccountDisabled
+	}
+
+	record.apply(opts)
+	record.UpdatedAt = time.Now()
+
+	if err := c.saveServiceAccount(ctx, record); err != nil {
+		return nil, kvError(op, err, "failed to save service account")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: action, UserID: record.ID})
+
+	return &record.ServiceAccount, nil
+}
+
+// RotateServiceAccountSecret replaces the client secret of a service
+// account. The old secret stops working at once; tokens already issued
+// stay valid until they expire.
+func (c *Client) RotateServiceAccountSecret(ctx context.Context, id string) (_ *ServiceAccountCredentials, err error) {
+	const op = "Client.RotateServiceAccountSecret"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	record, err := c.loadServiceAccount(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newServiceAccountSecret()
+	if err != nil {
+		return nil, NewAppError(op, err, "failed to generate client secret", 500)
+	}
+	record.SecretHash = hashSecret(secret)
+	record.UpdatedAt = time.Now()
+
+	if err := c.saveServiceAccount(ctx, record); err != nil {
+		return nil, kvError(op, err, "failed to save service account")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditServiceAccountSecretRotated, UserID: record.ID})
+
+	return &ServiceAccountCredentials{ServiceAccount: record.ServiceAccount, ClientSecret: secret}, nil
+}
+
+// DeleteServiceAccount deletes a service account and revokes its API keys.
+// Its tokens are rejected by ValidateToken and ValidateSession from then on.
+func (c *Client) DeleteServiceAccount(ctx context.Context, id string) (err error) {
+	const op = "Client.DeleteServiceAccount"
+	ctx, span := c.startSpan(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if _, err := c.loadServiceAccount(ctx, op, id); err != nil {
+		return err
+	}
+
+	keys, err := c.ListAPIKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := c.RevokeAPIKey(ctx, id, key.ID); err != nil && !IsInvalidAPIKey(err) {
+			return err
+		}
+	}
+
+	if err := c.kvDelete(ctx, getServiceAccountKey(id)); err != nil {
+		return kvError(op, err, "failed to delete service account")
+	}
+
+	c.audit(ctx, &AuditEvent{Action: AuditServiceAccountDeleted, UserID: id})
+
+	return nil
+}
+
+// ExchangeClientCredentials issues a short-lived token to a service account
+// in exchange for its ID and client secret, in the manner of the OAuth 2.0
+// client credentials grant.
+//
+// The token lasts ClientOptions.ServiceTokenTTL and cannot be refreshed.
+// Its claims have ServiceAccount set, UserID holding the account ID and
+// Roles holding the account's roles. ValidateSession accepts it and returns
+// the account in SessionInfo.ServiceAccount; ValidateToken rejects it,
+// since there is no user.
+//
+// Like Login, every exchange is counted by the MetricsRecorder and audited
+// as AuditClientCredentialsSucceeded or AuditClientCredentialsFailed.
+func (c *Client) ExchangeClientCredentials(ctx context.Context, clientID, clientSecret string) (_ *ServiceTokenResponse, err error) {
+	const op = "Client.ExchangeClientCredentials"
+	ctx, span := c.startSpan(ctx, op)
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordClientCredentials(ctx, authOutcome(err))
+		c.logAuth(ctx, slog.LevelInfo, op, clientID, start, err)
+		// IDs that cannot name an account are not worth an audit event
+		if err == nil {
+			c.audit(ctx, &AuditEvent{Action: AuditClientCredentialsSucceeded, UserID: clientID})
+		} else if IsServiceAccountID(clientID) {
+			c.audit(ctx, &AuditEvent{Action: AuditClientCredentialsFailed, UserID: clientID, Reason: authOutcome(err)})
+		}
+		endSpan(span, err)
+	}()
+
+	invalid := NewAppError(op, ErrInvalidCredentials, "invalid client credentials", 401)
+	if !IsServiceAccountID(clientID) || clientSecret == "" {
+		return nil, invalid
+	}
+
+	record, err := c.getServiceAccount(ctx, clientID)
+	if err != nil {
+		if isKVNotFound(err) {
+			return nil, invalid
+		}
+		return nil, kvError(op, err, "failed to read service account")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(clientSecret)), []byte(record.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	if record.Disabled {
+		return nil, NewAppError(op, ErrInvalidCredentials, "service account is disabled", 403)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.serviceTokenTTL)
+	claims := &Claims{
+		UserID:         record.ID,
+		ServiceAccount: true,
+		Roles:          record.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   record.ID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := c.signToken(op, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceTokenResponse{
+		Token:          token,
+		ExpiresAt:      expiresAt,
+		ServiceAccount: &record.ServiceAccount,
+	}, nil
+}
+
+// validateServiceAccount loads the account of a service account token,
+// rejecting tokens of deleted or disabled accounts
+func (c *Client) validateServiceAccount(ctx context.Context, op, id string) (*ServiceAccount, error) {
+	record, err := c.getServiceAccount(ctx, id)
+	if err != nil {
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrInvalidToken, "service account no longer exists", 401)
+		}
+		return nil, kvError(op, err, "failed to read service account")
+	}
+	if record.Disabled {
+		return nil, NewAppError(op, ErrInvalidToken, "service account is disabled", 401)
+	}
+	return &record.ServiceAccount, nil
+}
+
+// loadServiceAccount reads a service account, converting a missing account
+// into a 404 AppError
+func (c *Client) loadServiceAccount(ctx context.Context, op, id string) (*serviceAccountRecord, error) {
+	if !IsServiceAccountID(id) {
+		return nil, NewAppError(op, ErrServiceAccountNotFound, "service account not found", 404)
+	}
+
+	record, err := c.getServiceAccount(ctx, id)
+	if err != nil {
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrServiceAccountNotFound, "service account not found", 404)
+		}
+		return nil, kvError(op, err, "failed to read service account")
+	}
+	return record, nil
+}
+
+// getServiceAccount reads a stored service account, bypassing the KV cache
+// so deletions and disabling take effect at once
+func (c *Client) getServiceAccount(ctx context.Context, id string) (*serviceAccountRecord, error) {
+	data, err := c.kvFetch(ctx, getServiceAccountKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var record serviceAccountRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (c *Client) saveServiceAccount(ctx context.Context, record *serviceAccountRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return c.kvSet(ctx, getServiceAccountKey(record.ID), data, nil)
+}
+
+func (r *serviceAccountRecord) apply(opts *ServiceAccountOptions) {
+	r.Name = strings.TrimSpace(opts.Name)
+	r.Description = opts.Description
+	r.Roles = opts.Roles
+	r.Disabled = opts.Disabled
+}
+
+func newServiceAccountSecret() (string, error) {
+	secret := make([]byte, serviceAccountSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func getServiceAccountKey(id string) string {
+	return "serviceaccount:id:" + id
+}
@@ -0,0 +1,217 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingAuditSink keeps the events written to it
+type recordingAuditSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (s *recordingAuditSink) WriteAuditEvent(_ context.Context, event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// actions returns the recorded actions and clears them
+func (s *recordingAuditSink) actions() []AuditAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var actions []AuditAction
+	for _, event := range s.events {
+		actions = append(actions, event.Action)
+	}
+	s.events = nil
+	return actions
+}
+
+func TestServiceAccountLifecycle(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	sink := &recordingAuditSink{}
+	client.SetAuditSink(sink)
+
+	creds, err := client.CreateServiceAccount(ctx, &ServiceAccountOptions{Name: " deploy ", Roles: []string{"deployer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsServiceAccountID(creds.ID) || creds.Name != "deploy" || creds.ClientSecret == "" {
+		t.Fatalf("created %+v", creds)
+	}
+
+	account, err := client.GetServiceAccount(ctx, creds.ID)
+	if err != nil || !account.HasRole("deployer") {
+		t.Fatalf("GetServiceAccount = %+v, %v", account, err)
+	}
+	accounts, err := client.ListServiceAccounts(ctx)
+	if err != nil || len(accounts) != 1 || accounts[0].ID != creds.ID {
+		t.Fatalf("ListServiceAccounts = %+v, %v", accounts, err)
+	}
+
+	updated, err := client.UpdateServiceAccount(ctx, creds.ID, &ServiceAccountOptions{Name: "deploy", Roles: []string{"reader"}})
+	if err != nil || updated.HasRole("deployer") || !updated.HasRole("reader") {
+		t.Fatalf("UpdateServiceAccount = %+v, %v", updated, err)
+	}
+	if _, err := client.UpdateServiceAccount(ctx, creds.ID, &ServiceAccountOptions{Name: "deploy", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Updating a disabled account is not another disabling
+	if _, err := client.UpdateServiceAccount(ctx, creds.ID, &ServiceAccountOptions{Name: "deploy", Description: "paused", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := client.RotateServiceAccountSecret(ctx, creds.ID)
+	if err != nil || rotated.ClientSecret == creds.ClientSecret {
+		t.Fatalf("RotateServiceAccountSecret = %+v, %v", rotated, err)
+	}
+
+	if _, err := client.CreateAPIKey(ctx, creds.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteServiceAccount(ctx, creds.ID); err != nil {
+		t.Fatal(err)
+	}
+	if keys := kv.keys("apikey:"); len(keys) != 0 {
+		t.Fatalf("API keys %v left after deleting the account", keys)
+	}
+	if _, err := client.GetServiceAccount(ctx, creds.ID); !IsServiceAccountNotFound(err) {
+		t.Fatalf("GetServiceAccount after delete = %v, want not found", err)
+	}
+
+	want := []AuditAction{
+		AuditServiceAccountCreated,
+		AuditServiceAccountUpdated,
+		AuditServiceAccountDisabled,
+		AuditServiceAccountUpdated,
+		AuditServiceAccountSecretRotated,
+		AuditAPIKeyCreated,
+		AuditAPIKeyRevoked,
+		AuditServiceAccountDeleted,
+	}
+	if got := sink.actions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit actions %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"get", func() error { _, err := client.GetServiceAccount(ctx, creds.ID); return err }},
+		{"update", func() error {
+			_, err := client.UpdateServiceAccount(ctx, creds.ID, &ServiceAccountOptions{Name: "deploy"})
+			return err
+		}},
+		{"rotate", func() error { _, err := client.RotateServiceAccountSecret(ctx, creds.ID); return err }},
+		{"delete", func() error { return client.DeleteServiceAccount(ctx, creds.ID) }},
+		{"user ID", func() error { _, err := client.GetServiceAccount(ctx, "user-1"); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var appErr *AppError
+			if !IsServiceAccountNotFound(err) || !errors.As(err, &appErr) || appErr.Code != http.StatusNotFound {
+				t.Fatalf("%s of a missing account = %v, want 404", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestExchangeClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	metrics := &recordingMetrics{}
+	client, _ := newTestClient(t, &ClientOptions{Metrics: metrics})
+	sink := &recordingAuditSink{}
+	client.SetAuditSink(sink)
+
+	creds, err := client.CreateServiceAccount(ctx, &ServiceAccountOptions{Name: "ci", Roles: []string{"deployer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.actions()
+
+	resp, err := client.ExchangeClientCredentials(ctx, creds.ID, creds.ClientSecret)
+	if err != nil {
+		t.Fatalf("ExchangeClientCredentials: %v", err)
+	}
+	if resp.ServiceAccount.ID != creds.ID || resp.ExpiresAt.IsZero() {
+		t.Fatalf("response %+v", resp)
+	}
+	if got := sink.actions(); !reflect.DeepEqual(got, []AuditAction{AuditClientCredentialsSucceeded}) {
+		t.Fatalf("audit actions %v, want a successful exchange", got)
+	}
+
+	info, err := client.ValidateSession(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("ValidateSession: %v", err)
+	}
+	if info.User != nil || info.ServiceAccount == nil || info.ServiceAccount.ID != creds.ID ||
+		!info.Claims.ServiceAccount || !reflect.DeepEqual(info.Claims.Roles, []string{"deployer"}) {
+		t.Fatalf("session %+v, want the service account with its roles", info)
+	}
+	if _, err := client.ValidateToken(ctx, resp.Token); err == nil {
+		t.Fatal("ValidateToken accepted a service account token")
+	}
+
+	tests := []struct {
+		name         string
+		clientID     string
+		secret       string
+		wantCode     int
+		wantAudited  bool
+		disableFirst bool
+	}{
+		{name: "wrong secret", clientID: creds.ID, secret: "wrong", wantCode: http.StatusUnauthorized, wantAudited: true},
+		{name: "missing secret", clientID: creds.ID, wantCode: http.StatusUnauthorized, wantAudited: true},
+		{name: "unknown account", clientID: "sa_unknown", secret: "secret", wantCode: http.StatusUnauthorized, wantAudited: true},
+		{name: "not a service account ID", clientID: "alice@example.com", secret: "secret", wantCode: http.StatusUnauthorized},
+		{name: "disabled account", clientID: creds.ID, secret: creds.ClientSecret, wantCode: http.StatusForbidden, wantAudited: true, disableFirst: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.disableFirst {
+				if _, err := client.UpdateServiceAccount(ctx, creds.ID, &ServiceAccountOptions{Name: "ci", Disabled: true}); err != nil {
+					t.Fatal(err)
+				}
+				sink.actions()
+			}
+
+			_, err := client.ExchangeClientCredentials(ctx, tt.clientID, tt.secret)
+			var appErr *AppError
+			if !IsInvalidCredentials(err) || !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Fatalf("ExchangeClientCredentials = %v, want invalid credentials with %d", err, tt.wantCode)
+			}
+
+			var want []AuditAction
+			if tt.wantAudited {
+				want = []AuditAction{AuditClientCredentialsFailed}
+			}
+			if got := sink.actions(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("audit actions %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Disabling the account rejected the token issued before
+	if _, err := client.ValidateSession(ctx, resp.Token); !IsInvalidToken(err) {
+		t.Fatalf("token of a disabled account = %v, want invalid token", err)
+	}
+
+	want := []string{OutcomeSuccess}
+	for range tests {
+		want = append(want, OutcomeInvalidCredentials)
+	}
+	if !reflect.DeepEqual(metrics.clientCredentials, want) {
+		t.Fatalf("recorded outcomes %v, want %v", metrics.clientCredentials, want)
+	}
+}
@@ -0,0 +1,110 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultSessionTouchInterval is the default minimum interval between
+// session activity writes.
+const defaultSessionTouchInterval = time.Minute
+
+// createSession stores a new sliding session for the user
+func (c *Client) createSession(ctx context.Context, user *User, now, expiresAt time.Time) (*Session, error) {
+	const op = "Client.createSession"
+
+	session := &Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := c.saveSession(ctx, session, now); err != nil {
+		return nil, kvError(op, err, "failed to create session")
+	}
+
+	return session, nil
+}
+
+// touchSession checks the session referenced by the claims for idle and
+// absolute expiry, and records activity.
+//
+// Activity writes are coalesced: the session is only rewritten once the last
+// recorded activity is older than the touch interval. The recorded activity
+// may therefore lag the real activity by up to one touch interval, and a
+// session can end up to one touch interval before the configured idle timeout.
+func (c *Client) touchSession(ctx context.Context, claims *Claims, now time.Time) (*Session, error) {
+	const op = "Client.touchSession"
+
+	if claims.SessionID == "" {
+		return nil, NewAppError(op, ErrSessionNotFound, "token has no session", 401)
+	}
+
+	sessionKey := getSessionKey(claims.SessionID)
+	sessionData, err := c.kvGet(ctx, sessionKey)
+	if err != nil {
+		if isKVNotFound(err) {
+			return nil, NewAppError(op, ErrSessionNotFound, "session not found", 401)
+		}
+		return nil, kvError(op, err, "failed to get session")
+	}
+
+	session, err := sessionFromJSON(sessionData)
+	if err != nil {
+		return nil, NewAppError(op, err, "failed to parse session", 500)
+	}
+
+	if session.UserID != claims.UserID {
+		return nil, NewAppError(op, ErrSessionNotFound, "session not found", 401)
+	}
+
+	if !now.Before(session.deadline(c.sessionIdleTimeout)) {
+		// Best effort cleanup; the KV TTL removes the record eventually anyway
+		_ = c.kvDelete(ctx, sessionKey)
+		return nil, NewAppError(op, ErrSessionExpired, "session has expired", 401)
+	}
+
+	if now.Sub(session.LastSeenAt) >= c.sessionTouchInterval {
+		session.LastSeenAt = now
+		if err := c.saveSession(ctx, session, now); err != nil {
+			return nil, kvError(op, err, "failed to update session")
+		}
+	}
+
+	return session, nil
+}
+
+// saveSession writes a session with a TTL covering its remaining idle window
+func (c *Client) saveSession(ctx context.Context, session *Session, now time.Time) error {
+	sessionData, err := session.toJSON()
+	if err != nil {
+		return err
+	}
+
+	ttl := session.deadline(c.sessionIdleTimeout).Sub(now)
+	if ttl < minKVExpirationTTL {
+		ttl = minKVExpirationTTL
+	}
+
+	return c.kvSet(ctx, getSessionKey(session.ID), sessionData, &KVWriteOptions{
+		ExpirationTTL: int((ttl + time.Second - 1) / time.Second),
+	})
+}
+
+// deadline returns the time the session ends if there is no further activity
+func (s *Session) deadline(idleTimeout time.Duration) time.Time {
+	idleDeadline := s.LastSeenAt.Add(idleTimeout)
+	if idleDeadline.After(s.ExpiresAt) {
+		return s.ExpiresAt
+	}
+	return idleDeadline
+}
+
+func getSessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
@@ -0,0 +1,173 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock for Client.now
+type testClock struct {
+	t time.Time
+}
+
+func newTestClock(client *Client) *testClock {
+	clock := &testClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	client.now = func() time.Time { return clock.t }
+	return clock
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// loginSession registers and logs in a user and returns the token and its
+// session ID
+func loginSession(t *testing.T, client *Client) (string, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := client.VerifyToken(resp.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID == "" {
+		t.Fatal("token has no session")
+	}
+	return resp.Token, claims.SessionID
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{SessionIdleTimeout: 10 * time.Minute})
+	clock := newTestClock(client)
+	token, sessionID := loginSession(t, client)
+
+	// Activity keeps moving the idle deadline
+	for i := 0; i < 3; i++ {
+		clock.advance(9 * time.Minute)
+		info, err := client.ValidateSession(ctx, token)
+		if err != nil {
+			t.Fatalf("validation %d after 9m idle: %v", i, err)
+		}
+		if want := clock.t.Add(10 * time.Minute); !info.ExpiresAt.Equal(want) {
+			t.Fatalf("ExpiresAt = %v, want %v", info.ExpiresAt, want)
+		}
+		if info.Remaining != 10*time.Minute {
+			t.Fatalf("Remaining = %v, want 10m", info.Remaining)
+		}
+	}
+
+	clock.advance(10 * time.Minute)
+	if _, err := client.ValidateSession(ctx, token); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("validation after 10m idle = %v, want ErrSessionExpired", err)
+	}
+	if _, ok := kv.get(getSessionKey(sessionID)); ok {
+		t.Fatal("expired session record was not deleted")
+	}
+	if _, err := client.ValidateSession(ctx, token); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("validation after expiry = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionAbsoluteLifetime(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, &ClientOptions{
+		JWTExpirationHours: 1,
+		SessionIdleTimeout: 30 * time.Minute,
+	})
+	clock := newTestClock(client)
+	loginAt := clock.t
+	token, _ := loginSession(t, client)
+
+	clock.advance(20 * time.Minute)
+	if _, err := client.ValidateSession(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Past the last half hour the absolute expiry comes before the idle deadline
+	clock.advance(20 * time.Minute)
+	info, err := client.ValidateSession(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := loginAt.Add(time.Hour); !info.ExpiresAt.Equal(want) {
+		t.Fatalf("ExpiresAt = %v, want the absolute expiry %v", info.ExpiresAt, want)
+	}
+
+	clock.advance(20 * time.Minute)
+	if _, err := client.ValidateSession(ctx, token); err == nil {
+		t.Fatal("token accepted past its absolute lifetime")
+	}
+}
+
+func TestSessionTouchCoalescing(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{
+		SessionIdleTimeout:   10 * time.Minute,
+		SessionTouchInterval: time.Minute,
+	})
+	clock := newTestClock(client)
+	token, sessionID := loginSession(t, client)
+	loginAt := clock.t
+
+	writes := kv.count("PUT values")
+	for i := 0; i < 5; i++ {
+		clock.advance(10 * time.Second)
+		if _, err := client.ValidateSession(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := kv.count("PUT values") - writes; n != 0 {
+		t.Fatalf("%d session writes within the touch interval, want 0", n)
+	}
+
+	clock.advance(10 * time.Second)
+	info, err := client.ValidateSession(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := kv.count("PUT values") - writes; n != 1 {
+		t.Fatalf("%d session writes after the touch interval, want 1", n)
+	}
+	if want := loginAt.Add(time.Minute + 10*time.Minute); !info.ExpiresAt.Equal(want) {
+		t.Fatalf("ExpiresAt = %v, want %v", info.ExpiresAt, want)
+	}
+
+	data, _ := kv.get(getSessionKey(sessionID))
+	session, err := sessionFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.LastSeenAt.Equal(clock.t) {
+		t.Fatalf("LastSeenAt = %v, want %v", session.LastSeenAt, clock.t)
+	}
+}
+
+func TestLogoutDeletesSession(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, &ClientOptions{SessionIdleTimeout: 10 * time.Minute})
+	newTestClock(client)
+	token, sessionID := loginSession(t, client)
+
+	if _, ok := kv.get(getSessionKey(sessionID)); !ok {
+		t.Fatal("login did not store a session")
+	}
+	if err := client.Logout(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.get(getSessionKey(sessionID)); ok {
+		t.Fatal("session record still stored after logout")
+	}
+	if _, err := client.ValidateToken(ctx, token); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("ValidateToken after logout = %v, want ErrSessionNotFound", err)
+	}
+}
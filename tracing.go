@@ -0,0 +1,82 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the SDK's spans.
+const tracerName = "github.com/zolagz/cloudflare-auth-sdk"
+
+// Span attribute keys. Keys are reduced to their prefix so that span data
+// never contains email addresses or other identifiers.
+const (
+	attrKVKeyPrefix = attribute.Key("kv.key_prefix")
+	attrKVKeyCount  = attribute.Key("kv.key_count")
+	attrErrorCode   = attribute.Key("error.code")
+)
+
+// kvKeyPrefixSegments is the number of leading key segments kept in spans.
+const kvKeyPrefixSegments = 2
+
+// newTracer returns the SDK tracer from tp, or a no-op tracer if tp is nil
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// startSpan starts a span named after the operation
+func (c *Client) startSpan(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, op, trace.WithAttributes(attrs...))
+}
+
+// endSpan marks the span as failed if err is set and ends it. Only the
+// AppError code and the outcome label are recorded: error messages may
+// contain keys, email addresses and Cloudflare request URLs.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		var appErr *AppError
+		if errors.As(err, &appErr) {
+			span.SetAttributes(attrErrorCode.Int(appErr.Code))
+		}
+		span.SetStatus(codes.Error, spanOutcome(err))
+	}
+	span.End()
+}
+
+// spanOutcome returns the outcome label describing a failed operation:
+// the auth outcome, or the KV outcome for storage and other failures
+func spanOutcome(err error) string {
+	if outcome := authOutcome(err); outcome != OutcomeStorageError && outcome != OutcomeError {
+		return outcome
+	}
+	return kvOutcome(err)
+}
+
+// kvKeyAttr returns the span attribute describing a key or key prefix
+func kvKeyAttr(key string) attribute.KeyValue {
+	return attrKVKeyPrefix.String(kvKeyPrefix(key))
+}
+
+// kvKeyPrefix returns up to the first two colon-terminated segments of a
+// key, e.g. "user:email:" for "user:email:alice@example.com". Keys without
+// a separator yield an empty prefix.
+func kvKeyPrefix(key string) string {
+	end := 0
+	for i := 0; i < kvKeyPrefixSegments; i++ {
+		next := strings.IndexByte(key[end:], ':')
+		if next < 0 {
+			break
+		}
+		end += next + 1
+	}
+	return key[:end]
+}
@@ -0,0 +1,91 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingSpan records what endSpan sets on a span
+type recordingSpan struct {
+	noop.Span
+	status      codes.Code
+	description string
+	attrs       []attribute.KeyValue
+	errors      []error
+	ended       bool
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.status, s.description = code, description
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.attrs = append(s.attrs, kv...)
+}
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestEndSpanRecordsOutcomeOnly(t *testing.T) {
+	const key = "user:email:alice@example.com"
+	apiErr := fmt.Errorf("GET \"https://api.cloudflare.com/.../values/%s\": %w", key, apiError(http.StatusServiceUnavailable, nil))
+
+	tests := []struct {
+		name     string
+		err      error
+		wantDesc string
+		wantCode int
+	}{
+		{name: "success"},
+		{name: "invalid credentials", err: NewAppError("Client.Login", ErrInvalidCredentials, "invalid credentials", 401), wantDesc: OutcomeInvalidCredentials, wantCode: 401},
+		{name: "KV failure", err: kvError("Client.KVGet", apiErr, "failed to get key"), wantDesc: OutcomeServerError, wantCode: 503},
+		{name: "missing key", err: kvError("Client.KVGet", kvNotFoundError(), "failed to get key"), wantDesc: OutcomeNotFound, wantCode: 404},
+		{name: "missing namespace", err: kvError("Client.KVGet", apiError(http.StatusNotFound, nil), "failed to get key"), wantDesc: OutcomeClientError, wantCode: 502},
+		{name: "raw KV error", err: apiErr, wantDesc: OutcomeServerError},
+		{name: "timeout", err: context.DeadlineExceeded, wantDesc: OutcomeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := &recordingSpan{}
+			endSpan(span, tt.err)
+
+			if !span.ended {
+				t.Fatal("span not ended")
+			}
+			if len(span.errors) != 0 {
+				t.Fatalf("recorded errors %v; messages may contain keys", span.errors)
+			}
+			if tt.err == nil {
+				if span.status != codes.Unset || len(span.attrs) != 0 {
+					t.Fatalf("successful span has status %v, attributes %v", span.status, span.attrs)
+				}
+				return
+			}
+			if span.status != codes.Error || span.description != tt.wantDesc {
+				t.Errorf("status %v %q, want error %q", span.status, span.description, tt.wantDesc)
+			}
+			var code int64
+			for _, attr := range span.attrs {
+				if attr.Key == attrErrorCode {
+					code = attr.Value.AsInt64()
+				}
+			}
+			if code != int64(tt.wantCode) {
+				t.Errorf("error.code %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
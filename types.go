@@ -31,22 +31,97 @@ type LoginResponse struct {
 
 // Claims represents JWT claims.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+
+	// Extra holds custom claims added by BeforeLogin hooks
+	Extra map[string]interface{} `json:"ext,omitempty"`
+
+	// ServiceAccount marks tokens issued by ExchangeClientCredentials; their
+	// UserID is the service account's ID and Roles its roles
+	ServiceAccount bool     `json:"svc,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
+// Session represents a server-side login session used for sliding expiration.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // Absolute expiry, regardless of activity
+}
+
+// SessionInfo represents the result of a successful token validation.
+type SessionInfo struct {
+	User           *User
+	ServiceAccount *ServiceAccount // Set instead of User for service account tokens
+	Claims         *Claims
+	SessionID      string        // Empty when sliding sessions are disabled
+	ExpiresAt      time.Time     // Time the session ends if there is no further activity
+	Remaining      time.Duration // Remaining lifetime at validation time
+}
+
 // KVKey represents a key in the KV namespace with metadata.
 type KVKey struct {
 	Name       string      `json:"name"`
-	Expiration float64     `json:"expiration,omitempty"`
+	Expiration *time.Time  `json:"expiration,omitempty"` // Nil if the key does not expire
 	Metadata   interface{} `json:"metadata,omitempty"`
 }
 
+// KVPage represents one page of a key listing.
+type KVPage struct {
+	Keys   []KVKey `json:"keys"`
+	Cursor string  `json:"cursor,omitempty"` // Cursor of the next page; empty after the last page
+}
+
 // KVWriteOptions contains options for writing KV pairs.
 type KVWriteOptions struct {
-	ExpirationTTL int    // Time to live in seconds
-	Metadata      string // Optional metadata
+	ExpirationTTL int         // Time to live in seconds, at least 60
+	Expiration    time.Time   // Absolute expiration time, at least 60 seconds ahead; mutually exclusive with ExpirationTTL
+	Metadata      interface{} // Optional JSON-serializable metadata, at most 1024 bytes encoded
+}
+
+// KVEntry represents a value read together with its metadata.
+type KVEntry struct {
+	Key        string
+	Value      []byte
+	Metadata   interface{} // Decoded JSON metadata; nil if the key has none
+	Expiration *time.Time  // Nil if the key does not expire
+}
+
+// KVPair represents a key-value pair for bulk writes.
+type KVPair struct {
+	Key           string
+	Value         []byte
+	ExpirationTTL int         // Time to live in seconds, at least 60
+	Expiration    time.Time   // Absolute expiration time, at least 60 seconds ahead; mutually exclusive with ExpirationTTL
+	Metadata      interface{} // Optional JSON-serializable metadata, at most 1024 bytes encoded
+}
+
+// KVBulkResult represents the outcome of a bulk write.
+type KVBulkResult struct {
+	SuccessfulKeys   int      // Number of keys written
+	UnsuccessfulKeys []string // Keys that were not written and should be retried
+}
+
+// KVGetResult represents the outcome of reading one key in a bulk read.
+type KVGetResult struct {
+	Key   string
+	Value []byte
+	Err   error
+}
+
+// DecodeMetadata decodes the entry's metadata into v.
+func (e *KVEntry) DecodeMetadata(v interface{}) error {
+	data, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
 }
 
 // toJSON converts User to JSON bytes
@@ -63,6 +138,20 @@ func userFromJSON(data []byte) (*User, error) {
 	return &user, nil
 }
 
+// toJSON converts Session to JSON bytes
+func (s *Session) toJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// sessionFromJSON parses Session from JSON bytes
+func sessionFromJSON(data []byte) (*Session, error) {
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
 // ToUserInfo converts User to UserInfo
 func (u *User) ToUserInfo() UserInfo {
 	return UserInfo{
@@ -0,0 +1,115 @@
+package cloudflare_auth_sdk
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultUserCacheTTL is the default lifetime of cached user lookups.
+const defaultUserCacheTTL = time.Minute
+
+// userCache is a bounded, TTL-based LRU cache of users keyed by user ID.
+type userCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently used
+
+	// generation is bumped on every removal so that lookups started before
+	// an update or deletion do not re-add the old user.
+	generation atomic.Uint64
+}
+
+type userCacheEntry struct {
+	userID    string
+	user      User
+	expiresAt time.Time
+}
+
+func newUserCache(maxSize int, ttl time.Duration) *userCache {
+	return &userCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a copy of the cached user if present and not expired
+func (uc *userCache) get(userID string, now time.Time) (*User, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	elem, ok := uc.entries[userID]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*userCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		uc.removeElement(elem)
+		return nil, false
+	}
+
+	uc.order.MoveToFront(elem)
+	user := entry.user
+	return &user, true
+}
+
+// add stores a copy of the user, evicting the least recently used entry
+// when full, unless a user was removed since the lookup began at generation
+func (uc *userCache) add(user *User, generation uint64, now time.Time) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if uc.generation.Load() != generation {
+		return
+	}
+
+	if elem, ok := uc.entries[user.ID]; ok {
+		entry := elem.Value.(*userCacheEntry)
+		entry.user = *user
+		entry.expiresAt = now.Add(uc.ttl)
+		uc.order.MoveToFront(elem)
+		return
+	}
+
+	uc.entries[user.ID] = uc.order.PushFront(&userCacheEntry{
+		userID:    user.ID,
+		user:      *user,
+		expiresAt: now.Add(uc.ttl),
+	})
+
+	for uc.order.Len() > uc.maxSize {
+		uc.removeElement(uc.order.Back())
+	}
+}
+
+// remove drops the user from the cache
+func (uc *userCache) remove(userID string) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	uc.generation.Add(1)
+	if elem, ok := uc.entries[userID]; ok {
+		uc.removeElement(elem)
+	}
+}
+
+func (uc *userCache) removeElement(elem *list.Element) {
+	entry := uc.order.Remove(elem).(*userCacheEntry)
+	delete(uc.entries, entry.userID)
+}
+
+// InvalidateUser removes a user from the local user cache.
+//
+// Updates and deletions made through this client invalidate the cache
+// automatically; call InvalidateUser when another process changes the user.
+func (c *Client) InvalidateUser(userID string) {
+	if c.userCache != nil {
+		c.userCache.remove(userID)
+	}
+}
@@ -0,0 +1,117 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUserCache(t *testing.T) {
+	now := time.Now()
+	alice := &User{ID: "a", Email: "alice@example.com"}
+	bob := &User{ID: "b", Email: "bob@example.com"}
+	carol := &User{ID: "c", Email: "carol@example.com"}
+
+	tests := []struct {
+		name    string
+		run     func(uc *userCache)
+		at      time.Duration // Time of the final lookups, relative to now
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "hit before expiry",
+			run:     func(uc *userCache) { uc.add(alice, uc.generation.Load(), now) },
+			at:      59 * time.Second,
+			present: []string{"a"},
+		},
+		{
+			name:   "miss after expiry",
+			run:    func(uc *userCache) { uc.add(alice, uc.generation.Load(), now) },
+			at:     time.Minute,
+			absent: []string{"a"},
+		},
+		{
+			name: "evicts least recently used",
+			run: func(uc *userCache) {
+				uc.add(alice, uc.generation.Load(), now)
+				uc.add(bob, uc.generation.Load(), now)
+				uc.get("a", now) // Bob is now least recently used
+				uc.add(carol, uc.generation.Load(), now)
+			},
+			present: []string{"a", "c"},
+			absent:  []string{"b"},
+		},
+		{
+			name: "remove drops the user",
+			run: func(uc *userCache) {
+				uc.add(alice, uc.generation.Load(), now)
+				uc.remove("a")
+			},
+			absent: []string{"a"},
+		},
+		{
+			name: "lookup started before removal is not stored",
+			run: func(uc *userCache) {
+				generation := uc.generation.Load()
+				uc.remove("a") // e.g. DeleteUser while GetUserByID is in flight
+				uc.add(alice, generation, now)
+			},
+			absent: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newUserCache(2, time.Minute)
+			tt.run(uc)
+
+			for _, id := range tt.present {
+				if _, ok := uc.get(id, now.Add(tt.at)); !ok {
+					t.Errorf("user %s not cached", id)
+				}
+			}
+			for _, id := range tt.absent {
+				if _, ok := uc.get(id, now.Add(tt.at)); ok {
+					t.Errorf("user %s cached", id)
+				}
+			}
+		})
+	}
+}
+
+func TestUserCacheReturnsCopies(t *testing.T) {
+	now := time.Now()
+	uc := newUserCache(1, time.Minute)
+	uc.add(&User{ID: "a", Email: "alice@example.com"}, uc.generation.Load(), now)
+
+	user, _ := uc.get("a", now)
+	user.Email = "mallory@example.com"
+
+	if user, _ := uc.get("a", now); user.Email != "alice@example.com" {
+		t.Fatalf("cached user modified through a returned copy: %s", user.Email)
+	}
+}
+
+func TestValidateTokenAfterDeleteUserWithCache(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, &ClientOptions{UserCacheSize: 10, UserCacheTTL: time.Hour})
+
+	if _, err := client.Register(ctx, "alice@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Login(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateToken(ctx, resp.Token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	if err := client.DeleteUser(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateToken(ctx, resp.Token); !IsUserNotFound(err) {
+		t.Fatalf("ValidateToken after DeleteUser = %v, want user not found", err)
+	}
+}
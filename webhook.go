@@ -0,0 +1,201 @@
+package cloudflare_auth_sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook request headers.
+const (
+	WebhookIDHeader        = "Webhook-Id"
+	WebhookSignatureHeader = "Webhook-Signature"
+)
+
+// DefaultWebhookTolerance is the default maximum age of a webhook signature.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// webhookMaxBody is the largest webhook body ReadWebhookEvent accepts.
+const webhookMaxBody = 1 << 20
+
+// WebhookEventType identifies the kind of a webhook event.
+type WebhookEventType string
+
+// Events sent by the client. Applications may send their own event types,
+// such as email changes, with WebhookDispatcher.Enqueue.
+const (
+	WebhookUserCreated WebhookEventType = "user.created"
+	WebhookUserDeleted WebhookEventType = "user.deleted"
+)
+
+// WebhookEvent is the JSON body of a webhook request.
+type WebhookEvent struct {
+	ID   string           `json:"id"`
+	Type WebhookEventType `json:"type"`
+	Time time.Time        `json:"time"`
+	User *UserInfo        `json:"user,omitempty"`
+	Data json.RawMessage  `json:"data,omitempty"` // Application-defined payload
+}
+
+// SignWebhook returns the Webhook-Signature header value for payload sent
+// at time t: "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the HMAC covers
+// "<unix seconds>.<payload>".
+func SignWebhook(payload []byte, secret string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + webhookMAC(payload, secret, ts)
+}
+
+func webhookMAC(payload []byte, secret, ts string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'.'})
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the Webhook-Signature header of a received
+// payload. Signatures older or further in the future than tolerance are
+// rejected to prevent replays (default: DefaultWebhookTolerance).
+//
+// The header may carry several v1 signatures, e.g. while the secret is
+// rotated; one valid signature is enough.
+func VerifyWebhookSignature(payload []byte, header, secret string, tolerance time.Duration) error {
+	if tolerance == 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected := []byte(webhookMAC(payload, secret, ts))
+	for _, sig := range signatures {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// ReadWebhookEvent reads a webhook request body, verifies its signature and
+// decodes the event.
+//
+// Example:
+//
+//	http.HandleFunc("/webhooks/auth", func(w http.ResponseWriter, r *http.Request) {
+//	    event, err := sdk.ReadWebhookEvent(r, secret, 0)
+//	    if err != nil {
+//	        http.Error(w, "invalid webhook", http.StatusBadRequest)
+//	        return
+//	    }
+//	    // Deliveries are at least once; skip event.ID if already handled
+//	    w.WriteHeader(http.StatusNoContent)
+//	})
+func ReadWebhookEvent(r *http.Request, secret string, tolerance time.Duration) (*WebhookEvent, error) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifyWebhookSignature(payload, r.Header.Get(WebhookSignatureHeader), secret, tolerance); err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeFailed, err)
+	}
+	return &event, nil
+}
+
+// webhookStatusError reports a webhook response outside the 2xx range.
+type webhookStatusError struct {
+	StatusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook receiver answered %d", e.StatusCode)
+}
+
+// retryable reports whether the receiver may accept the event later
+func (e *webhookStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout
+}
+
+// SendWebhook makes one signed delivery of event to url with client
+// (default: http.DefaultClient). Responses outside the 2xx range are errors.
+//
+// WebhookDispatcher calls it for every attempt; it is exported for sending
+// events without persistence and for testing receivers.
+func SendWebhook(ctx context.Context, client *http.Client, url, secret string, event *WebhookEvent) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, event.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(payload, secret, time.Now()))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func prepareWebhookEvent(event *WebhookEvent) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+}
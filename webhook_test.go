@@ -0,0 +1,314 @@
+package cloudflare_auth_sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "webhook-secret"
+	payload := []byte(`{"id":"evt-1","type":"user.created"}`)
+	now := time.Now()
+	valid := SignWebhook(payload, secret, now)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		header    string
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{name: "valid", payload: payload, header: valid},
+		{name: "wrong secret", payload: payload, header: SignWebhook(payload, "other-secret", now), wantErr: true},
+		{name: "tampered payload", payload: []byte(`{"id":"evt-2","type":"user.created"}`), header: valid, wantErr: true},
+		{name: "too old", payload: payload, header: SignWebhook(payload, secret, now.Add(-10*time.Minute)), wantErr: true},
+		{name: "too far in the future", payload: payload, header: SignWebhook(payload, secret, now.Add(10*time.Minute)), wantErr: true},
+		{name: "within a custom tolerance", payload: payload, header: SignWebhook(payload, secret, now.Add(-10*time.Minute)), tolerance: time.Hour},
+		{name: "one of several signatures", payload: payload, header: SignWebhook(payload, "old-secret", now) + ", " + valid[len("t=0000000000,"):]},
+		{name: "missing signature", payload: payload, header: valid[:len("t=0000000000")], wantErr: true},
+		{name: "missing timestamp", payload: payload, header: valid[len("t=0000000000,"):], wantErr: true},
+		{name: "empty header", payload: payload, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.payload, tt.header, secret, tt.tolerance)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("VerifyWebhookSignature = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("error %v does not wrap ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+// webhookReceiver is a test receiver answering with the queued statuses,
+// then 204
+type webhookReceiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	events   []*WebhookEvent
+	received chan struct{}
+}
+
+func newWebhookReceiver(t *testing.T, secret string, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	r := &webhookReceiver{t: t, secret: secret, statuses: statuses, received: make(chan struct{}, 16)}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	event, err := ReadWebhookEvent(req, r.secret, 0)
+	if err != nil {
+		r.t.Errorf("ReadWebhookEvent: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if id := req.Header.Get(WebhookIDHeader); id != event.ID {
+		r.t.Errorf("Webhook-Id %q, want %q", id, event.ID)
+	}
+
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+	r.received <- struct{}{}
+}
+
+// wait blocks until the receiver has handled n more requests
+func (r *webhookReceiver) wait(n int) {
+	r.t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			r.t.Fatalf("webhook %d of %d not received", i+1, n)
+		}
+	}
+}
+
+// runDispatcher runs d until the test ends
+func runDispatcher(t *testing.T, d *WebhookDispatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	})
+}
+
+// waitIdle waits until d has no pending deliveries, in memory or in KV
+func waitIdle(t *testing.T, d *WebhookDispatcher, kv *fakeKV) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Pending() > 0 || len(kv.keys(d.key("pending", ""))) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d deliveries still pending", d.Pending())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestDispatcher(t *testing.T, client *Client, url string) *WebhookDispatcher {
+	t.Helper()
+	d, err := client.NewWebhookDispatcher(&WebhookOptions{
+		URL:    url,
+		Secret: "webhook-secret",
+		Retry:  &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestWebhookDispatcherDeliversSignedEvents(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	receiver, server := newWebhookReceiver(t, "webhook-secret")
+	d := newTestDispatcher(t, client, server.URL)
+	runDispatcher(t, d)
+
+	user, err := client.Register(ctx, "alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.wait(1)
+	waitIdle(t, d, kv)
+
+	event := receiver.events[0]
+	if event.Type != WebhookUserCreated || event.User == nil || event.User.ID != user.ID {
+		t.Fatalf("event %+v, want user.created for %s", event, user.ID)
+	}
+	if keys := kv.keys("webhook:"); len(keys) != 0 {
+		t.Fatalf("KV keys %v left after delivery", keys)
+	}
+}
+
+func TestWebhookDispatcherRetriesServerErrors(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	receiver, server := newWebhookReceiver(t, "webhook-secret", http.StatusInternalServerError, http.StatusServiceUnavailable)
+	d := newTestDispatcher(t, client, server.URL)
+	runDispatcher(t, d)
+
+	event := &WebhookEvent{Type: "user.email_changed"}
+	if err := d.Enqueue(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	receiver.wait(3)
+	waitIdle(t, d, kv)
+
+	for i, got := range receiver.events {
+		if got.ID != event.ID {
+			t.Errorf("attempt %d delivered %s, want %s", i, got.ID, event.ID)
+		}
+	}
+	if keys := kv.keys("webhook:"); len(keys) != 0 {
+		t.Fatalf("KV keys %v left after delivery", keys)
+	}
+}
+
+func TestWebhookDispatcherFailsOnClientErrors(t *testing.T) {
+	ctx := context.Background()
+	client, kv := newTestClient(t, nil)
+	receiver, server := newWebhookReceiver(t, "webhook-secret", http.StatusGone)
+	d := newTestDispatcher(t, client, server.URL)
+	runDispatcher(t, d)
+
+	event := &WebhookEvent{Type: "user.email_changed"}
+	if err := d.Enqueue(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	receiver.wait(1)
+	waitIdle(t, d, kv)
+
+	// No redelivery after a permanent failure
+	select {
+	case <-receiver.received:
+		t.Fatal("event delivered again after a 410 response")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	data, ok := kv.get(d.key("failed", event.ID))
+	if !ok {
+		t.Fatal("failed delivery not recorded in KV")
+	}
+	var delivery webhookDelivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Attempts != 1 || delivery.Event.ID != event.ID || delivery.LastError == "" {
+		t.Fatalf("failed delivery %+v, want one attempt with an error", delivery)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWebhookDispatcherRedactsErrors(t *testing.T) {
+	ctx := context.Background()
+	var logs lockedBuffer
+	client, kv := newTestClient(t, &ClientOptions{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})
+
+	// The receiver is gone, and its URL carries credentials
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	const secret = "url-secret"
+	target, err := url.Parse(server.URL + "/hook?token=" + secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.User = url.UserPassword("hooks", secret)
+	d := newTestDispatcher(t, client, target.String())
+	runDispatcher(t, d)
+
+	event := &WebhookEvent{Type: "user.email_changed"}
+	if err := d.Enqueue(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, d, kv)
+
+	data, ok := kv.get(d.key("failed", event.ID))
+	if !ok {
+		t.Fatal("failed delivery not recorded in KV")
+	}
+	var delivery webhookDelivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Attempts != 3 || delivery.LastError != "webhook request failed" {
+		t.Fatalf("failed delivery %+v, want 3 attempts with a redacted error", delivery)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "webhook delivery failed") {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery failure not logged: %s", logs.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if out := logs.String(); strings.Contains(out, secret) || strings.Contains(out, server.URL) {
+		t.Fatalf("logs contain the receiver URL: %s", out)
+	}
+}
+
+func TestWebhookDispatcherRunLoadsPendingDeliveries(t *testing.T) {
+	client, kv := newTestClient(t, nil)
+	receiver, server := newWebhookReceiver(t, "webhook-secret")
+	d := newTestDispatcher(t, client, server.URL)
+
+	// Left behind by a previous process
+	event := WebhookEvent{ID: "evt-1", Type: WebhookUserDeleted, Time: time.Now().UTC()}
+	data, err := json.Marshal(&webhookDelivery{Event: event, Attempts: 1, NextAttempt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv.put(d.key("pending", event.ID), data)
+
+	runDispatcher(t, d)
+	receiver.wait(1)
+	waitIdle(t, d, kv)
+
+	if got := receiver.events[0]; got.ID != event.ID || got.Type != event.Type {
+		t.Fatalf("delivered %+v, want %+v", got, event)
+	}
+}
@@ -0,0 +1,442 @@
+package cloudflare_auth_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWebhookMaxAttempts is the default number of delivery attempts.
+	defaultWebhookMaxAttempts = 10
+
+	// defaultWebhookInitialBackoff is the default delay before the first redelivery.
+	defaultWebhookInitialBackoff = time.Second
+
+	// defaultWebhookMaxBackoff is the default upper bound of a redelivery delay.
+	defaultWebhookMaxBackoff = 10 * time.Minute
+
+	// defaultWebhookTimeout is the default time limit of one delivery attempt.
+	defaultWebhookTimeout = 10 * time.Second
+
+	// webhookFailedTTL is how long failed deliveries are kept in KV.
+	webhookFailedTTL = 30 * 24 * time.Hour
+)
+
+// WebhookOptions contains options for a WebhookDispatcher.
+type WebhookOptions struct {
+	Name   string             // Delivery queue namespace in KV (default: "default")
+	URL    string             // Receiver URL (required)
+	Secret string             // HMAC-SHA256 signing secret (required)
+	Events []WebhookEventType // Event types to send (default: all)
+
+	// Retry controls redeliveries (default: 10 attempts with backoff from
+	// one second up to ten minutes). MaxRetryAfter is not used.
+	Retry *RetryPolicy
+
+	Timeout    time.Duration // Time limit of one delivery attempt (default: 10s)
+	HTTPClient *http.Client  // Client for deliveries (default: http.DefaultClient)
+}
+
+// WebhookDispatcher delivers signed webhook events to one receiver.
+//
+// Events are persisted in KV under "webhook:<name>:pending:<event ID>"
+// before delivery and removed once the receiver answers with a 2xx status,
+// so pending deliveries survive restarts. Deliveries that fail permanently,
+// with a 4xx status other than 408 and 429 or after the last attempt, are
+// moved to "webhook:<name>:failed:<event ID>" for 30 days.
+//
+// Delivery is at least once: receivers should skip event IDs they have
+// already handled.
+//
+// A queue has a single consumer. There are no leases: every process running
+// a dispatcher with the same Name loads and delivers the same pending
+// events, so give each process its own Name or run the dispatcher in one
+// process only. Pending entries have no TTL and are only loaded when Run
+// starts; entries of a Name that is no longer run stay in KV until they
+// are deleted.
+type WebhookDispatcher struct {
+	client *Client
+	opts   WebhookOptions
+	retry  RetryPolicy
+	events map[WebhookEventType]bool
+
+	mu      sync.Mutex
+	pending map[string]*webhookDelivery
+	wake    chan struct{}
+}
+
+// webhookDelivery is the persisted state of one pending delivery.
+type webhookDelivery struct {
+	Event       WebhookEvent `json:"event"`
+	Attempts    int          `json:"attempts"`
+	NextAttempt time.Time    `json:"next_attempt"`
+	LastError   string       `json:"last_error,omitempty"`
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher that sends user.created
+// and user.deleted events from Register and DeleteUser.
+//
+// Like hooks, it must be created before the client is used. Deliveries
+// only happen while Run is running.
+//
+// Example:
+//
+//	webhooks, err := client.NewWebhookDispatcher(&sdk.WebhookOptions{
+//	    URL:    "https://example.com/webhooks/auth",
+//	    Secret: os.Getenv("WEBHOOK_SECRET"),
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	go webhooks.Run(ctx)
+func (c *Client) NewWebhookDispatcher(opts *WebhookOptions) (*WebhookDispatcher, error) {
+	if opts == nil {
+		return nil, ErrInvalidConfig
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	d := &WebhookDispatcher{
+		client:  c,
+		opts:    *opts,
+		pending: make(map[string]*webhookDelivery),
+		wake:    make(chan struct{}, 1),
+	}
+	if d.opts.Name == "" {
+		d.opts.Name = "default"
+	}
+	if d.opts.Timeout == 0 {
+		d.opts.Timeout = defaultWebhookTimeout
+	}
+
+	retry := RetryPolicy{
+		MaxAttempts:    defaultWebhookMaxAttempts,
+		InitialBackoff: defaultWebhookInitialBackoff,
+		MaxBackoff:     defaultWebhookMaxBackoff,
+	}
+	if opts.Retry != nil {
+		if opts.Retry.MaxAttempts != 0 {
+			retry.MaxAttempts = opts.Retry.MaxAttempts
+		}
+		if opts.Retry.InitialBackoff != 0 {
+			retry.InitialBackoff = opts.Retry.InitialBackoff
+		}
+		if opts.Retry.MaxBackoff != 0 {
+			retry.MaxBackoff = opts.Retry.MaxBackoff
+		}
+	}
+	d.retry = retry
+
+	if len(opts.Events) > 0 {
+		d.events = make(map[WebhookEventType]bool, len(opts.Events))
+		for _, t := range opts.Events {
+			d.events[t] = true
+		}
+	}
+
+	c.AfterRegister(func(ctx context.Context, user *User) {
+		d.enqueueUser(ctx, WebhookUserCreated, user)
+	})
+	c.AfterDeleteUser(func(ctx context.Context, user *User) {
+		d.enqueueUser(ctx, WebhookUserDeleted, user)
+	})
+
+	return d, nil
+}
+
+// validate checks the webhook options
+func (o *WebhookOptions) validate() error {
+	u, err := url.Parse(o.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	if o.Secret == "" {
+		return errors.New("webhook secret is required")
+	}
+	if o.Timeout < 0 {
+		return errors.New("webhook timeout must not be negative")
+	}
+	if o.Retry != nil && (o.Retry.MaxAttempts < 0 || o.Retry.InitialBackoff < 0 || o.Retry.MaxBackoff < 0) {
+		return errors.New("webhook retry values must not be negative")
+	}
+	return nil
+}
+
+// Enqueue queues an event for delivery. ID and Time are set if empty.
+// Events of types not in WebhookOptions.Events are ignored.
+//
+// If the event cannot be persisted, it is still delivered by this process
+// but lost on restart, and the storage error is returned.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, event *WebhookEvent) error {
+	const op = "WebhookDispatcher.Enqueue"
+
+	if event == nil || event.Type == "" {
+		return NewAppError(op, ErrInvalidInput, "webhook event type is required", 400)
+	}
+	if d.events != nil && !d.events[event.Type] {
+		return nil
+	}
+
+	prepareWebhookEvent(event)
+	delivery := &webhookDelivery{
+		Event:       *event,
+		NextAttempt: event.Time,
+	}
+
+	// Only Run touches a delivery once it is queued
+	err := d.save(ctx, delivery)
+
+	d.mu.Lock()
+	d.pending[event.ID] = delivery
+	d.mu.Unlock()
+	d.notify()
+	if err != nil {
+		return kvError(op, err, "failed to persist webhook delivery")
+	}
+	return nil
+}
+
+// enqueueUser queues a user event from a hook, logging failures
+func (d *WebhookDispatcher) enqueueUser(ctx context.Context, eventType WebhookEventType, user *User) {
+	info := user.ToUserInfo()
+	event := &WebhookEvent{Type: eventType, User: &info}
+
+	// The operation has completed; persist the event even if the caller gives up
+	if err := d.Enqueue(context.WithoutCancel(ctx), event); err != nil {
+		d.log(ctx, slog.LevelError, "webhook event not persisted", &webhookDelivery{Event: *event}, err)
+	}
+}
+
+// Run loads pending deliveries from KV and delivers events until ctx is
+// done. It returns the context error, or a storage error if the pending
+// deliveries cannot be loaded.
+//
+// Run one dispatcher per receiver and process; deliveries are attempted
+// one at a time. Pending deliveries persisted by other processes after Run
+// has started are not picked up.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	const op = "WebhookDispatcher.Run"
+
+	if err := d.load(ctx); err != nil {
+		return kvError(op, err, "failed to load pending webhook deliveries")
+	}
+
+	for {
+		next := d.deliverDue(ctx)
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-d.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Pending returns the number of deliveries waiting to be sent.
+func (d *WebhookDispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// load adds the persisted pending deliveries to the queue
+func (d *WebhookDispatcher) load(ctx context.Context) error {
+	prefix := d.key("pending", "")
+	cursor := ""
+	for {
+		keys, next, err := d.client.kvList(ctx, prefix, kvListMaxLimit, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			data, err := d.client.kvFetch(ctx, key.Name)
+			if err != nil {
+				if isKVNotFound(err) {
+					continue
+				}
+				return err
+			}
+
+			var delivery webhookDelivery
+			if err := json.Unmarshal(data, &delivery); err != nil {
+				d.log(ctx, slog.LevelError, "corrupt webhook delivery skipped", &webhookDelivery{Event: WebhookEvent{ID: key.Name}}, err)
+				continue
+			}
+
+			d.mu.Lock()
+			if _, ok := d.pending[delivery.Event.ID]; !ok {
+				d.pending[delivery.Event.ID] = &delivery
+			}
+			d.mu.Unlock()
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// deliverDue attempts all due deliveries and returns the time of the next
+// one, or zero if none are pending
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) time.Time {
+	now := time.Now()
+
+	d.mu.Lock()
+	var due []*webhookDelivery
+	for _, delivery := range d.pending {
+		if !delivery.NextAttempt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		d.attempt(ctx, delivery)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var next time.Time
+	for _, delivery := range d.pending {
+		if next.IsZero() || delivery.NextAttempt.Before(next) {
+			next = delivery.NextAttempt
+		}
+	}
+	return next
+}
+
+// attempt makes one delivery attempt and updates the delivery state
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *webhookDelivery) {
+	attemptCtx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	err := SendWebhook(attemptCtx, d.opts.HTTPClient, d.opts.URL, d.opts.Secret, &delivery.Event)
+	cancel()
+
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; the delivery stays pending
+		return
+	}
+
+	delivery.Attempts++
+	if err == nil {
+		d.remove(ctx, delivery)
+		d.log(ctx, slog.LevelDebug, "webhook delivered", delivery, nil)
+		return
+	}
+
+	delivery.LastError = webhookErrorMessage(err)
+
+	var statusErr *webhookStatusError
+	permanent := errors.As(err, &statusErr) && !statusErr.retryable()
+	if permanent || delivery.Attempts >= d.retry.MaxAttempts {
+		d.fail(ctx, delivery)
+		d.log(ctx, slog.LevelError, "webhook delivery failed", delivery, err)
+		return
+	}
+
+	delivery.NextAttempt = time.Now().Add(d.retry.backoff(delivery.Attempts))
+
+	if err := d.save(ctx, delivery); err != nil {
+		d.log(ctx, slog.LevelWarn, "webhook delivery state not persisted", delivery, err)
+	}
+	d.log(ctx, slog.LevelWarn, "webhook delivery attempt failed", delivery, err)
+}
+
+// remove drops a delivered event from the queue and from KV
+func (d *WebhookDispatcher) remove(ctx context.Context, delivery *webhookDelivery) {
+	d.mu.Lock()
+	delete(d.pending, delivery.Event.ID)
+	d.mu.Unlock()
+
+	// A key left behind is delivered again after a restart
+	if err := d.client.kvDelete(ctx, d.key("pending", delivery.Event.ID)); err != nil {
+		d.log(ctx, slog.LevelWarn, "delivered webhook not removed from KV", delivery, err)
+	}
+}
+
+// fail moves a delivery to the failed deliveries in KV
+func (d *WebhookDispatcher) fail(ctx context.Context, delivery *webhookDelivery) {
+	data, err := json.Marshal(delivery)
+	if err == nil {
+		err = d.client.kvSet(ctx, d.key("failed", delivery.Event.ID), data, &KVWriteOptions{
+			ExpirationTTL: int(webhookFailedTTL / time.Second),
+		})
+	}
+	if err != nil {
+		d.log(ctx, slog.LevelWarn, "failed webhook delivery not recorded", delivery, err)
+	}
+
+	d.remove(ctx, delivery)
+}
+
+// save persists a pending delivery
+func (d *WebhookDispatcher) save(ctx context.Context, delivery *webhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return d.client.kvSet(ctx, d.key("pending", delivery.Event.ID), data, nil)
+}
+
+// notify wakes Run after an event was queued
+func (d *WebhookDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *WebhookDispatcher) key(state, eventID string) string {
+	return "webhook:" + d.opts.Name + ":" + state + ":" + eventID
+}
+
+func (d *WebhookDispatcher) log(ctx context.Context, level slog.Level, msg string, delivery *webhookDelivery, err error) {
+	attrs := []slog.Attr{
+		slog.String("webhook.name", d.opts.Name),
+		slog.String("webhook.event_id", delivery.Event.ID),
+		slog.String("webhook.type", string(delivery.Event.Type)),
+		slog.Int("webhook.attempts", delivery.Attempts),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String(logKeyError, webhookErrorMessage(err)))
+	}
+	d.client.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// webhookErrorMessage describes a delivery failure without the receiver
+// URL, which may carry credentials in its userinfo or query
+func webhookErrorMessage(err error) string {
+	var statusErr *webhookStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "webhook request timed out"
+	default:
+		return "webhook request failed"
+	}
+}